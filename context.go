@@ -0,0 +1,158 @@
+package route
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type contextKey struct{}
+
+// matchInfo is what the Handler learned about a request while routing
+// it, carried in the request's context.
+type matchInfo struct {
+	subdomain string
+	path      string   // The cleaned path that was matched.
+	parts     []string // The elements of the cleaned path.
+	escaped   string   // The escaped path, for RawVars.
+	caps      []capture
+	raw       bool   // Whether the suffix variable was captured raw.
+	pat       string // The pattern that matched.
+	tab       *table // The routes it was matched against, for names.
+}
+
+func getInfo(r *http.Request) *matchInfo {
+	mi, _ := r.Context().Value(contextKey{}).(*matchInfo)
+	if mi == nil {
+		return &matchInfo{}
+	}
+	return mi
+}
+
+func withInfo(r *http.Request, mi *matchInfo) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, mi))
+}
+
+// Subdomain returns the part of the request's host in front of the
+// Handler's Domain, e.g. "tenant" for "tenant.example.com" and "a.b"
+// for "a.b.example.com". It is empty for the domain itself, for hosts
+// outside of it, and when the Handler has no Domain.
+func Subdomain(r *http.Request) string {
+	return getInfo(r).subdomain
+}
+
+// MatchedMethod returns the method the request was routed with. It is
+// always r.Method, but reads better in handlers registered with
+// AnyMethodVar.
+func MatchedMethod(r *http.Request) string {
+	return r.Method
+}
+
+// NormalizedPath returns the cleaned path the request was routed by,
+// e.g. "/a/c" for "/a//b/../c/", so that handlers needing the
+// canonical form don't have to clean it again. It includes Unicode
+// normalization if the Handler does it. For requests that weren't
+// routed by a Handler it is path.Clean of the request's path.
+func NormalizedPath(r *http.Request) string {
+	if mi := getInfo(r); mi.path != "" {
+		return mi.path
+	}
+	return cleanPath(r.URL.Path)
+}
+
+// Vars returns the variables captured for the request, keyed by their
+// names in the pattern, e.g. ":userID". The values are decoded, just
+// like the ones FormValue returns, so "/users/J%C3%BCrgen" gives
+// "Jürgen".
+func Vars(r *http.Request) map[string]string {
+	vars := map[string]string{}
+	for _, c := range getInfo(r).caps {
+		vars[c.name] = c.value
+	}
+	return vars
+}
+
+// MatchedWithVars reports whether the request was routed to a pattern
+// with variables, as opposed to a static one. Static routes are often
+// safer to cache.
+func MatchedWithVars(r *http.Request) bool {
+	return len(getInfo(r).caps) > 0
+}
+
+// RawVars is like Vars but returns the variables as they appeared in
+// the request's path, still escaped, so "/users/J%C3%BCrgen" gives
+// "J%C3%BCrgen" and "/tag/a+b" gives "a+b". This is for handlers that
+// need the exact bytes, like ones checking signatures or proxying. If
+// the escaped path can't be lined up with the one that was matched,
+// because it has encoded slashes or dots, the decoded values are
+// escaped again instead.
+func RawVars(r *http.Request) map[string]string {
+	mi := getInfo(r)
+	esc := splitPath(mi.escaped)
+	aligned := len(esc) == len(mi.parts)
+	for i := 0; aligned && i < len(esc); i++ {
+		p, err := url.PathUnescape(esc[i])
+		aligned = err == nil && p == mi.parts[i]
+	}
+	if !aligned {
+		esc = make([]string, len(mi.parts))
+		for i, p := range mi.parts {
+			esc[i] = url.PathEscape(p)
+		}
+	}
+	vars := map[string]string{}
+	for _, c := range mi.caps {
+		switch {
+		case c.name[0] == ':':
+			vars[c.name] = esc[c.i]
+		case mi.raw:
+			vars[c.name] = c.value
+		default:
+			vars[c.name] = strings.Join(esc[c.i:], "/")
+		}
+	}
+	return vars
+}
+
+// TemplateData returns what routing learned about the request in a
+// form ready to merge into the data of an html/template:
+//
+//	"Params"   map[string]string of the captured variables, keyed by
+//	           their names without the ':' or '*', so a template can
+//	           use {{.Params.userID}}
+//	"Pattern"  the pattern that matched, e.g. "/users/:userID"
+//	"Route"    the pattern's name, or "" if it has none
+//
+// For requests that weren't routed, Params is empty and the others are
+// "".
+func TemplateData(r *http.Request) map[string]interface{} {
+	mi := getInfo(r)
+	params := map[string]string{}
+	for _, c := range mi.caps {
+		params[c.name[1:]] = c.value
+	}
+	return map[string]interface{}{"Params": params, "Pattern": mi.pat, "Route": mi.name()}
+}
+
+// name returns the name of the pattern that matched, the first in
+// order if it has several.
+func (mi *matchInfo) name() string {
+	if mi.tab == nil {
+		return ""
+	}
+	return mi.tab.names()[mi.pat]
+}
+
+func subdomain(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !strings.HasSuffix(host, "."+domain) {
+		return ""
+	}
+	return host[:len(host)-len(domain)-1]
+}
@@ -0,0 +1,162 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSubdomain(t *testing.T) {
+	h := &Handler{Domain: "example.com"}
+	h.Get("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Subdomain(r)))
+	})
+	for host, want := range map[string]string{
+		"tenant.example.com":      "tenant",
+		"Tenant.Example.com:8080": "tenant",
+		"a.b.example.com":         "a.b",
+		"tenant.example.com.":     "tenant",
+		"example.com":             "",
+		"example.com:443":         "",
+		"badexample.com":          "",
+		"tenant.example.org":      "",
+	} {
+		r := httptest.NewRequest("GET", "/dashboard", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: got %d %q, want %q", host, w.Code, w.Body.String(), want)
+		}
+	}
+
+	h = &Handler{}
+	h.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Subdomain(r)))
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Host = "tenant.example.com"
+	w := httptest.NewRecorder()
+	if h.ServeHTTP(w, r); w.Body.String() != "" {
+		t.Errorf("without a Domain got %q", w.Body.String())
+	}
+}
+
+func TestVars(t *testing.T) {
+	h := &Handler{}
+	show := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%v %v", Vars(r), RawVars(r))
+	}
+	h.Get("/users/:name/tags/:tag", show)
+	h.Get("/files/*path", show)
+	h.RawSuffix("GET", "/raw/*key", show)
+	for target, want := range map[string]string{
+		"/users/J%C3%BCrgen/tags/a+b": "map[:name:J\u00fcrgen :tag:a+b] map[:name:J%C3%BCrgen :tag:a+b]",
+		"/users/a%20b/tags/c%2Bd":     "map[:name:a b :tag:c+d] map[:name:a%20b :tag:c%2Bd]",
+		"/files/a%20b/c+d":            "map[*path:a b/c+d] map[*path:a%20b/c+d]",
+		"/files/a%2Fb/c":              "map[*path:a/b/c] map[*path:a/b/c]",
+		"/raw/a%2Fb/../c":             "map[*key:a%2Fb/../c] map[*key:a%2Fb/../c]",
+		"/users/x/tags/%E2%9C%93?q=1": "map[:name:x :tag:\u2713] map[:name:x :tag:%E2%9C%93]",
+	} {
+		r := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Body.String(); got != want {
+			t.Errorf("%s: got %s, want %s", target, got, want)
+		}
+	}
+	r := httptest.NewRequest("GET", "/users/x/tags/y", nil)
+	if len(Vars(r)) != 0 || len(RawVars(r)) != 0 {
+		t.Error("a request that wasn't routed has variables")
+	}
+}
+
+func TestMatchedWithVars(t *testing.T) {
+	h := &Handler{}
+	show := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, MatchedWithVars(r))
+	}
+	h.Get("/static/page", show)
+	h.Get("/users/:id", show)
+	h.Get("/files/*path", show)
+	h.Get("/items/:id?", show)
+	for target, want := range map[string]string{
+		"/static/page": "false",
+		"/users/5":     "true",
+		"/files/a/b":   "true",
+		"/items":       "false",
+		"/items/1":     "true",
+	} {
+		if w := do(h, "GET", target); w.Body.String() != want {
+			t.Errorf("%s: got %s, want %s", target, w.Body.String(), want)
+		}
+	}
+}
+
+func TestNormalizedPath(t *testing.T) {
+	h := &Handler{}
+	h.Get("/a/*rest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(NormalizedPath(r)))
+	})
+	for target, want := range map[string]string{
+		"/a/b/c":         "/a/b/c",
+		"//a//b/./c/":    "/a/b/c",
+		"/x/../a/b/../c": "/a/c",
+		"/a/b%20c/?q=1":  "/a/b c",
+		"/a/%2e%2e/a/b":  "/a/b",
+	} {
+		if got := do(h, "GET", target).Body.String(); got != want {
+			t.Errorf("%s: got %q, want %q", target, got, want)
+		}
+	}
+	r := httptest.NewRequest("GET", "/x//y/", nil)
+	if got := NormalizedPath(r); got != "/x/y" {
+		t.Errorf("for a request that wasn't routed got %q", got)
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	h := &Handler{}
+	var data map[string]interface{}
+	save := func(w http.ResponseWriter, r *http.Request) { data = TemplateData(r) }
+	h.Get("/users/:userID/files/*path", save, "file")
+	h.Get("/items/:id?", save, "items")
+	h.Get("/about", save)
+	for _, c := range []struct {
+		target string
+		want   map[string]interface{}
+	}{
+		{"/users/5/files/a/b", map[string]interface{}{
+			"Params":  map[string]string{"userID": "5", "path": "a/b"},
+			"Pattern": "/users/:userID/files/*path",
+			"Route":   "file",
+		}},
+		{"/items", map[string]interface{}{
+			"Params":  map[string]string{},
+			"Pattern": "/items",
+			"Route":   "items",
+		}},
+		{"/items/3", map[string]interface{}{
+			"Params":  map[string]string{"id": "3"},
+			"Pattern": "/items/:id",
+			"Route":   "items",
+		}},
+		{"/about", map[string]interface{}{
+			"Params":  map[string]string{},
+			"Pattern": "/about",
+			"Route":   "",
+		}},
+	} {
+		data = nil
+		do(h, "GET", c.target)
+		if !reflect.DeepEqual(data, c.want) {
+			t.Errorf("%s: got %v, want %v", c.target, data, c.want)
+		}
+	}
+	want := map[string]interface{}{"Params": map[string]string{}, "Pattern": "", "Route": ""}
+	if got := TemplateData(httptest.NewRequest("GET", "/", nil)); !reflect.DeepEqual(got, want) {
+		t.Errorf("for a request that wasn't routed got %v", got)
+	}
+}
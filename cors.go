@@ -0,0 +1,78 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig is how to answer CORS preflight requests.
+type CORSConfig struct {
+	AllowOrigins     []string // "*" allows any origin.
+	AllowMethods     []string // Defaults to the pattern's methods.
+	AllowHeaders     []string
+	AllowCredentials bool
+	MaxAge           int // In seconds; 0 leaves it to the browser.
+}
+
+// Preflight sets how preflight requests to a pattern registered on the
+// DefaultHandler are answered.
+func Preflight(pat string, cfg CORSConfig) {
+	DefaultHandler.Preflight(pat, cfg)
+}
+
+// Preflight sets how CORS preflight requests to the registered pattern
+// are answered, in preference to the Handler's CORS config, so that an
+// endpoint can allow different headers than the rest. It panics if
+// nothing is registered with the pattern.
+func (h *Handler) Preflight(pat string, cfg CORSConfig) {
+	ts := h.registered(pat)
+	if ts == nil {
+		panic("route: there is no route with that pattern")
+	}
+	for _, t := range ts {
+		t.preflight = &cfg
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// preflight answers the preflight request r for the routes at t.
+func (cfg *CORSConfig) preflight(w http.ResponseWriter, r *http.Request, t *trie) {
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	allowed := ""
+	for _, o := range cfg.AllowOrigins {
+		if o == "*" && !cfg.AllowCredentials {
+			allowed = "*"
+			break
+		}
+		if o == "*" || strings.EqualFold(o, origin) {
+			allowed = origin
+			break
+		}
+	}
+	if allowed == "" {
+		w.WriteHeader(204)
+		return
+	}
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = t.methods()
+	}
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(cfg.AllowHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowHeaders, ", "))
+	}
+	if cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if cfg.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,71 @@
+package route
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	h := &Handler{CORS: &CORSConfig{AllowOrigins: []string{"*"}, AllowHeaders: []string{"Content-Type"}}}
+	h.Get("/public", ok("public"))
+	h.Get("/upload", ok("upload"))
+	h.Put("/upload", ok("upload"))
+	h.Preflight("/upload", CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com"},
+		AllowHeaders:     []string{"Content-Type", "X-Upload-Token"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	h.Opt("/plain", ok("options"))
+	h.Get("/items/:id?", ok("items"))
+	h.Preflight("/items/:id?", CORSConfig{AllowOrigins: []string{"https://app.example.com"}})
+	for _, c := range []struct {
+		path, origin string
+		want         map[string]string
+	}{
+		{"/public", "https://other.com", map[string]string{
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET",
+			"Access-Control-Allow-Headers": "Content-Type",
+		}},
+		{"/upload", "https://app.example.com", map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.com",
+			"Access-Control-Allow-Methods":     "GET, PUT",
+			"Access-Control-Allow-Headers":     "Content-Type, X-Upload-Token",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Max-Age":           "600",
+		}},
+		{"/upload", "https://other.com", map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{"/items", "https://app.example.com", map[string]string{
+			"Access-Control-Allow-Origin":  "https://app.example.com",
+			"Access-Control-Allow-Headers": "",
+		}},
+		{"/items/5", "https://app.example.com", map[string]string{
+			"Access-Control-Allow-Origin":  "https://app.example.com",
+			"Access-Control-Allow-Headers": "",
+		}},
+	} {
+		r := httptest.NewRequest("OPTIONS", c.path, nil)
+		r.Header.Set("Origin", c.origin)
+		r.Header.Set("Access-Control-Request-Method", "PUT")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != 204 {
+			t.Errorf("%s from %s: got %d", c.path, c.origin, w.Code)
+		}
+		for k, v := range c.want {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s from %s: %s is %q, want %q", c.path, c.origin, k, got, v)
+			}
+		}
+	}
+	// An OPTIONS request that isn't a preflight is routed as usual.
+	if w := do(h, "OPTIONS", "/plain"); w.Body.String() != "options" {
+		t.Errorf("plain OPTIONS got %d %q", w.Code, w.Body.String())
+	}
+	if !panics(func() { h.Preflight("/nope", CORSConfig{}) }) {
+		t.Error("a preflight config for an unknown pattern didn't panic")
+	}
+}
@@ -0,0 +1,52 @@
+package route
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ETag sets w's ETag header to tag and reports whether the request's
+// If-None-Match header already matches it, in which case it has
+// responded with 304 Not Modified (412 Precondition Failed for methods
+// other than GET and HEAD) and the handler should return:
+//
+//	if route.ETag(w, r, version) {
+//		return
+//	}
+//
+// The tag is quoted if it isn't already, and may be weak, like
+// `W/"v1"`. Tags are compared weakly, as RFC 9110 requires for
+// If-None-Match, so "v1" matches `W/"v1"`. An If-None-Match of "*"
+// matches any tag.
+func ETag(w http.ResponseWriter, r *http.Request, tag string) bool {
+	if !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		tag = `"` + tag + `"`
+	}
+	w.Header().Set("ETag", tag)
+	inm := r.Header.Values("If-None-Match")
+	if len(inm) == 0 || !etagMatch(strings.Join(inm, ","), tag) {
+		return false
+	}
+	if r.Method == "GET" || r.Method == "HEAD" {
+		h := w.Header()
+		delete(h, "Content-Type")
+		delete(h, "Content-Length")
+		w.WriteHeader(304)
+	} else {
+		http.Error(w, "412 precondition failed", 412)
+	}
+	return true
+}
+
+// etagMatch reports whether the list of entity tags in an
+// If-None-Match header weakly matches tag.
+func etagMatch(list, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
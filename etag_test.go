@@ -0,0 +1,43 @@
+package route
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	for _, c := range []struct {
+		method, tag, inm string
+		etag             string
+		code             int
+	}{
+		{"GET", "v1", "", `"v1"`, 200},
+		{"GET", "v1", `"v1"`, `"v1"`, 304},
+		{"GET", "v1", `"v2"`, `"v1"`, 200},
+		{"GET", "v1", `"v0", "v1"`, `"v1"`, 304},
+		{"GET", "v1", `W/"v1"`, `"v1"`, 304},
+		{"GET", `W/"v1"`, `"v1"`, `W/"v1"`, 304},
+		{"HEAD", `"v1"`, `W/"v1"`, `"v1"`, 304},
+		{"GET", "v1", "*", `"v1"`, 304},
+		{"GET", "v1", `"v"`, `"v1"`, 200},
+		{"PUT", "v1", `"v1"`, `"v1"`, 412},
+		{"PUT", "v1", "*", `"v1"`, 412},
+		{"PUT", "v1", `"v2"`, `"v1"`, 200},
+	} {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if c.inm != "" {
+			r.Header.Set("If-None-Match", c.inm)
+		}
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "text/plain")
+		if !ETag(w, r, c.tag) {
+			w.Write([]byte("body"))
+		}
+		if w.Code != c.code || w.Header().Get("ETag") != c.etag {
+			t.Errorf("%s %s with If-None-Match %s: got %d, ETag %s", c.method, c.tag, c.inm, w.Code, w.Header().Get("ETag"))
+		}
+		if c.code == 304 && (w.Body.Len() != 0 || w.Header().Get("Content-Type") != "") {
+			t.Errorf("%s with If-None-Match %s: 304 has body %q, Content-Type %q", c.tag, c.inm, w.Body.String(), w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package route
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// File returns a handler that serves the named file from disk. It
+// delegates to http.ServeContent, so Range requests get a 206 Partial
+// Content, and If-Modified-Since and the other conditional headers are
+// answered with the file's modification time. A missing file gets a
+// 404; the file is opened anew for every request.
+func File(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(name)
+		if err != nil {
+			fileError(w, err)
+			return
+		}
+		defer f.Close()
+		serveFile(w, r, f)
+	}
+}
+
+// FS returns a handler that serves files from fsys, picking the file
+// with the suffix variable varName:
+//
+//	route.Get("/static/*filepath", route.FS(os.DirFS("public"), "*filepath"))
+//
+// Files are served like File serves them. Directories, and paths that
+// fsys considers invalid, get a 404; there are no directory listings.
+func FS(fsys fs.FS, varName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+Vars(r)[varName]), "/")
+		if name == "" || !fs.ValidPath(name) {
+			http.Error(w, "404 page not found", 404)
+			return
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			fileError(w, err)
+			return
+		}
+		defer f.Close()
+		serveFile(w, r, f)
+	}
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, f fs.File) {
+	fi, err := f.Stat()
+	if err != nil {
+		fileError(w, err)
+		return
+	}
+	if fi.IsDir() {
+		http.Error(w, "404 page not found", 404)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		// ServeContent needs to seek to serve ranges.
+		b, err := io.ReadAll(f)
+		if err != nil {
+			fileError(w, err)
+			return
+		}
+		rs = bytes.NewReader(b)
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), rs)
+}
+
+func fileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "404 page not found", 404)
+		return
+	}
+	http.Error(w, "500 internal server error", 500)
+}
@@ -0,0 +1,66 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFiles(t *testing.T) {
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a.txt":     {Data: []byte("0123456789"), ModTime: mod},
+		"dir/b.txt": {Data: []byte("bbb"), ModTime: mod},
+	}
+	disk := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(disk, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(disk, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{}
+	h.Get("/static/*filepath", FS(fsys, "*filepath"))
+	h.Get("/a.txt", File(disk))
+	h.Get("/missing", File(filepath.Join(t.TempDir(), "missing")))
+	for _, c := range []struct {
+		target, header, value string
+		code                  int
+		body, contentRange    string
+	}{
+		{"/static/a.txt", "", "", 200, "0123456789", ""},
+		{"/static/a.txt", "Range", "bytes=2-5", 206, "2345", "bytes 2-5/10"},
+		{"/static/a.txt", "Range", "bytes=-3", 206, "789", "bytes 7-9/10"},
+		{"/static/a.txt", "Range", "bytes=20-", 416, "", "bytes */10"},
+		{"/static/a.txt", "If-Modified-Since", mod.Format(http.TimeFormat), 304, "", ""},
+		{"/static/a.txt", "If-Modified-Since", mod.Add(-time.Hour).Format(http.TimeFormat), 200, "0123456789", ""},
+		{"/static/dir/b.txt", "", "", 200, "bbb", ""},
+		{"/static/dir", "", "", 404, "", ""},
+		{"/static/nope.txt", "", "", 404, "", ""},
+		{"/static/dir/../a.txt", "", "", 200, "0123456789", ""},
+		{"/a.txt", "Range", "bytes=2-5", 206, "2345", "bytes 2-5/10"},
+		{"/a.txt", "If-Modified-Since", mod.Format(http.TimeFormat), 304, "", ""},
+		{"/missing", "", "", 404, "", ""},
+	} {
+		r := httptest.NewRequest("GET", c.target, nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s with %s %q: got %d, want %d", c.target, c.header, c.value, w.Code, c.code)
+			continue
+		}
+		if c.code/100 == 2 && w.Body.String() != c.body {
+			t.Errorf("%s with %s %q: got body %q, want %q", c.target, c.header, c.value, w.Body.String(), c.body)
+		}
+		if got := w.Header().Get("Content-Range"); got != c.contentRange {
+			t.Errorf("%s with %s %q: Content-Range is %q, want %q", c.target, c.header, c.value, got, c.contentRange)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthCheck registers a GET handler at the given path on the
+// DefaultHandler that reports the result of check.
+func HealthCheck(path string, check func() error) {
+	DefaultHandler.HealthCheck(path, check)
+}
+
+// HealthCheck registers a GET handler at the given path that responds
+// with 200 and {"status":"ok"} when check returns nil, and with 503
+// and the error otherwise. A nil check is always healthy.
+func (h *Handler) HealthCheck(path string, check func() error) {
+	h.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{"status": "ok"}
+		code := 200
+		if check != nil {
+			if err := check(); err != nil {
+				body = map[string]string{"status": "unavailable", "error": err.Error()}
+				code = 503
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	})
+}
@@ -0,0 +1,37 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	var err error
+	h := &Handler{}
+	h.HealthCheck("/healthz", func() error { return err })
+	h.HealthCheck("/livez", nil)
+	for _, c := range []struct {
+		path string
+		err  error
+		code int
+		want map[string]string
+	}{
+		{"/healthz", nil, 200, map[string]string{"status": "ok"}},
+		{"/healthz", errors.New("db down"), 503, map[string]string{"status": "unavailable", "error": "db down"}},
+		{"/livez", errors.New("ignored"), 200, map[string]string{"status": "ok"}},
+	} {
+		err = c.err
+		w := do(h, "GET", c.path)
+		if w.Code != c.code {
+			t.Errorf("%s with %v: got %d, want %d", c.path, c.err, w.Code, c.code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type is %q", c.path, ct)
+		}
+		var body map[string]string
+		if e := json.Unmarshal(w.Body.Bytes(), &body); e != nil || len(body) != len(c.want) || body["status"] != c.want["status"] || body["error"] != c.want["error"] {
+			t.Errorf("%s with %v: body %q", c.path, c.err, w.Body.String())
+		}
+	}
+}
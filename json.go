@@ -0,0 +1,68 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultJSONDecoder is used by DecodeJSON.
+var DefaultJSONDecoder = &JSONDecoder{MaxBytes: 1 << 20}
+
+// DecodeJSON decodes the request's JSON body into dst using the
+// DefaultJSONDecoder.
+func DecodeJSON(r *http.Request, dst interface{}) error {
+	return DefaultJSONDecoder.Decode(r, dst)
+}
+
+// A JSONDecoder decodes JSON request bodies.
+type JSONDecoder struct {
+	// MaxBytes, if positive, is the largest body that will be read.
+	MaxBytes int64
+
+	// DisallowUnknownFields makes fields in the body that dst has no
+	// place for an error.
+	DisallowUnknownFields bool
+}
+
+// Decode reads the request's body, which must hold exactly one JSON
+// value, and decodes it into dst. The errors are meant to be shown to
+// whoever sent the request. A nil body is empty.
+func (d *JSONDecoder) Decode(r *http.Request, dst interface{}) error {
+	body := r.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	if d.MaxBytes > 0 {
+		body = http.MaxBytesReader(nil, body, d.MaxBytes)
+	}
+	dec := json.NewDecoder(body)
+	if d.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(dst)
+	if err == nil {
+		if dec.Decode(&struct{}{}) != io.EOF {
+			return errors.New("route: request body must hold a single JSON value")
+		}
+		return nil
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("route: request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("route: request body has malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("route: request body has malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("route: request body has the wrong type for field %q at offset %d", typeErr.Field, typeErr.Offset)
+	case errors.As(err, &tooLarge):
+		return fmt.Errorf("route: request body is larger than %d bytes", tooLarge.Limit)
+	}
+	return fmt.Errorf("route: request body: %v", err)
+}
@@ -0,0 +1,51 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	strict := &JSONDecoder{MaxBytes: 32, DisallowUnknownFields: true}
+	for _, c := range []struct {
+		d    *JSONDecoder
+		body string
+		err  string
+	}{
+		{DefaultJSONDecoder, `{"name":"a","count":2}`, ""},
+		{DefaultJSONDecoder, `{"name":"a","extra":1}`, ""},
+		{DefaultJSONDecoder, ``, "route: request body is empty"},
+		{DefaultJSONDecoder, `{"name":`, "route: request body has malformed JSON"},
+		{DefaultJSONDecoder, `{"name":}`, "route: request body has malformed JSON at offset 9"},
+		{DefaultJSONDecoder, `{"count":"2"}`, `route: request body has the wrong type for field "count" at offset 12`},
+		{DefaultJSONDecoder, `{} {}`, "route: request body must hold a single JSON value"},
+		{strict, `{"name":"a","extra":1}`, `route: request body: json: unknown field "extra"`},
+		{strict, `{"name":"` + strings.Repeat("a", 32) + `"}`, "route: request body is larger than 32 bytes"},
+	} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		var v item
+		got := ""
+		if err := c.d.Decode(r, &v); err != nil {
+			got = err.Error()
+		}
+		if got != c.err {
+			t.Errorf("%s: got error %q, want %q", c.body, got, c.err)
+		}
+	}
+	r := &http.Request{Method: "POST"}
+	if err := DecodeJSON(r, &item{}); err == nil || err.Error() != "route: request body is empty" {
+		t.Errorf("a nil body got %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a","count":2}`))
+	var v item
+	if err := DecodeJSON(r, &v); err != nil || v != (item{"a", 2}) {
+		t.Errorf("got %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,324 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A capture is a variable captured while matching a request, from
+// the ith element of the path onward.
+type capture struct {
+	name, value string
+	i           int
+}
+
+// A matcher finds the node that matches a request's path. At each
+// node it tries the static child first, then the variable, and when a
+// branch leads nowhere it backs up and tries the next one, so
+// variables only match what static patterns don't.
+//
+// The invariant is this: of the patterns that match a path, the first
+// found is the one that, at the first element where it differs from
+// each of the others, has a static element where they have a variable.
+// Since the walk is depth first, that holds at any depth; a variable
+// near the root never shadows a longer static pattern, so with "/a/:x"
+// and "/a/b/c" registered, "/a/b/c" goes to the latter, and with
+// "/a/:x/c" and "/a/b/*rest", "/a/b/c" goes to the latter as well.
+// Priorities, when set, are weighed before this order, and so is
+// specificity when the Handler is MostSpecific.
+type matcher struct {
+	h     *Handler
+	r     *http.Request
+	parts []string
+	caps  []capture
+	// Whether to consider every candidate, for priorities or
+	// specificity.
+	prioritized bool
+	// Whether a pattern would have matched but for a variable over
+	// MaxVarLength.
+	tooLong bool
+	// The first variable whose value a constraint rejected, if a
+	// pattern would have matched otherwise.
+	rejected *capture
+	// The condition the request met at the node being yielded, if
+	// the route it goes to is conditional.
+	cond  *cond
+	trace func(format string, args ...interface{}) // Nil unless debugging.
+}
+
+// match returns the node with routes that matches the path, or nil,
+// and leaves the variables captured on the way in m.caps and the
+// condition the request met, if any, in m.cond. Normally
+// that's the first node found. Once priorities or specificity are in
+// play every candidate is considered, and the first better than all
+// the others wins.
+func (m *matcher) match(t *trie) *trie {
+	var best *trie
+	var caps []capture
+	var c *cond
+	m.each(t, 0, func(t *trie) bool {
+		if best == nil || m.better(t, best) {
+			best, caps, c = t, append([]capture{}, m.caps...), m.cond
+		}
+		return !m.prioritized
+	})
+	m.caps, m.cond = caps, c
+	return best
+}
+
+// better reports whether candidate t should win over the best one
+// found so far: by priority, then, if the Handler is MostSpecific, by
+// having more static elements and then fewer variables.
+func (m *matcher) better(t, best *trie) bool {
+	if t.priority != best.priority {
+		return t.priority > best.priority
+	}
+	if !m.h.MostSpecific {
+		return false
+	}
+	static, vars := specificity(t.pat)
+	bestStatic, bestVars := specificity(best.pat)
+	if static != bestStatic {
+		return static > bestStatic
+	}
+	return vars < bestVars
+}
+
+// specificity counts the static elements and the variables in a
+// cleaned pattern.
+func specificity(pat string) (static, vars int) {
+	for _, part := range splitPath(pat) {
+		if part[0] == ':' || part[0] == '*' {
+			vars++
+		} else {
+			static++
+		}
+	}
+	return static, vars
+}
+
+// each calls yield with every node with routes that matches parts[i:]
+// below t, in order of precedence, until yield returns true. While
+// yield runs, m.caps holds the variables captured on the way. each
+// reports whether yield returned true.
+func (m *matcher) each(t *trie, i int, yield func(*trie) bool) bool {
+	if i == len(m.parts) {
+		if t.empty() {
+			if m.trace != nil {
+				m.trace("  no pattern ends here")
+			}
+			return false
+		}
+		if t.internal && !m.h.isInternal(m.r) {
+			if m.trace != nil {
+				m.trace("  %s is internal", t.pat)
+			}
+			return false
+		}
+		if !t.expiry.IsZero() && !time.Now().Before(t.expiry) {
+			if m.trace != nil {
+				m.trace("  %s expired at %s", t.pat, t.expiry.Format(time.RFC3339))
+			}
+			return false
+		}
+		if !m.meets(t) {
+			return false
+		}
+		return yield(t)
+	}
+	part := m.parts[i]
+	// Try to match exactly first.
+	if part[0] != ':' && part[0] != '*' {
+		if t2, ok := t.t[part]; ok {
+			if m.trace != nil {
+				m.trace("  %q matched exactly", part)
+			}
+			if m.each(t2, i+1, yield) {
+				return true
+			}
+			if m.trace != nil {
+				m.trace("  backing up from %q", part)
+			}
+		}
+	}
+	// Try to use a variable instead.
+	if t.varName == "" {
+		if m.trace != nil {
+			m.trace("  %q matched nothing at depth %d", part, i+1)
+		}
+		return false
+	}
+	if max := m.h.MaxVars; max > 0 && len(m.caps) >= max {
+		if m.trace != nil {
+			m.trace("  %q would be variable number %d", part, len(m.caps)+1)
+		}
+		return false
+	}
+	t2 := t.t[t.varName]
+	if t.varName[0] == '*' {
+		v := strings.Join(m.parts[i:], "/")
+		if t2.raw {
+			v = rawSuffix(m.r.URL.EscapedPath(), i)
+		}
+		if m.trace != nil {
+			m.trace("  %q captured as %s=%q", part, t.varName, v)
+		}
+		if t2.empty() {
+			return false
+		}
+		if m.overLength(t.varName, v) {
+			if !m.tooLong && m.couldMatch(t2, len(m.parts), capture{t.varName, v, i}) {
+				m.tooLong = true
+			}
+			return false
+		}
+		m.caps = append(m.caps, capture{t.varName, v, i})
+		done := m.each(t2, len(m.parts), yield)
+		m.caps = m.caps[:len(m.caps)-1]
+		return done
+	}
+	if t2.constraint != nil && !t2.constraint.ok(part) {
+		if m.trace != nil {
+			m.trace("  %q is not an %s for %s", part, t2.constraint.key, t.varName)
+		}
+		if c := (capture{t.varName, part, i}); m.rejected == nil && m.couldMatch(t2, i+1, c) {
+			m.rejected = &c
+		}
+		return false
+	}
+	if m.overLength(t.varName, part) {
+		if !m.tooLong && m.couldMatch(t2, i+1, capture{t.varName, part, i}) {
+			m.tooLong = true
+		}
+		return false
+	}
+	if m.trace != nil {
+		m.trace("  %q captured as %s", part, t.varName)
+	}
+	m.caps = append(m.caps, capture{t.varName, part, i})
+	done := m.each(t2, i+1, yield)
+	m.caps = m.caps[:len(m.caps)-1]
+	if !done && m.trace != nil {
+		m.trace("  backing up from %s", t.varName)
+	}
+	return done
+}
+
+// meets reports whether the request meets the conditions, if any, of
+// the routes at t for its method, and leaves the one it met in m.cond.
+// A node whose routes for the method are all conditional, none of them
+// met, doesn't match, so the walk goes on to other patterns. One with
+// no routes for the method at all still matches, for a 405.
+func (m *matcher) meets(t *trie) bool {
+	m.cond = nil
+	cs := t.conds[m.r.Method]
+	for _, c := range cs {
+		if c.ok(m.r) {
+			m.cond = c
+			return true
+		}
+		if m.trace != nil {
+			m.trace("  %s %s requires %s", m.r.Method, t.pat, c.key)
+		}
+	}
+	if _, ok := t.verbs[m.r.Method]; len(cs) > 0 && !ok && t.anyMethod == nil {
+		if m.trace != nil {
+			m.trace("  no conditions met")
+		}
+		return false
+	}
+	return true
+}
+
+// overLength reports whether v is too long to be captured as the
+// variable.
+func (m *matcher) overLength(name, v string) bool {
+	if max := m.h.MaxVarLength; max > 0 && len(v) > max {
+		if m.trace != nil {
+			m.trace("  %d bytes is too long for %s", len(v), name)
+		}
+		return true
+	}
+	return false
+}
+
+// couldMatch reports whether parts[i:] would match below t had c been
+// captured, to tell a request that only fails because of c's value
+// from one that matches nothing anyway. It leaves m as it was.
+func (m *matcher) couldMatch(t *trie, i int, c capture) bool {
+	trace, cond, tooLong, rejected := m.trace, m.cond, m.tooLong, m.rejected
+	m.trace = nil
+	m.caps = append(m.caps, c)
+	found := m.each(t, i, func(*trie) bool { return true })
+	m.caps = m.caps[:len(m.caps)-1]
+	m.trace, m.cond, m.tooLong, m.rejected = trace, cond, tooLong, rejected
+	return found
+}
+
+// Explain returns a human-readable account of how the DefaultHandler
+// would route a request with the given method and path.
+func Explain(method, path string) string {
+	return DefaultHandler.Explain(method, path)
+}
+
+// Explain returns a human-readable account of how the Handler would
+// route a request with the given method and path: the trace Debug
+// would log, one step per line, and where it stopped, e.g.
+//
+//	POST /users/5
+//	  "users" matched exactly
+//	  "5" captured as :userID
+//	  method POST not allowed (GET, PUT registered): 405
+//
+// It's meant for tests and debugging; nothing is served. The path may
+// have a query, for GetQuery's conditions and MaxURLLength, which
+// counts the path and query together. The request explained has no
+// headers and no remote address, so conditions on those aren't met
+// and internal patterns don't match.
+func (h *Handler) Explain(method, path string) string {
+	var b strings.Builder
+	trace := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+	trace("%s %s", method, path)
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		u = &url.URL{Path: path}
+	}
+	if uri := u.RequestURI(); h.MaxURLLength > 0 && len(uri) > h.MaxURLLength {
+		trace("  URI is %d bytes long: 414", len(uri))
+		return b.String()
+	}
+	r := &http.Request{Method: method, URL: u, Header: http.Header{}}
+	tab := h.table()
+	m := &matcher{h: h, r: r, parts: splitPath(h.normalize(u.Path)), prioritized: tab.prioritized || h.MostSpecific, trace: trace}
+	t := m.match(&tab.trie)
+	switch {
+	case t == nil && m.tooLong:
+		trace("  no pattern matches without a variable that's too long: 414")
+		return b.String()
+	case t == nil && m.rejected != nil && h.HandleConstraintFail != nil:
+		trace("  no pattern matches without %s=%q: constraint failure", m.rejected.name, m.rejected.value)
+		return b.String()
+	case t == nil:
+		trace("  no pattern matches: 404")
+		return b.String()
+	}
+	_, ok := t.verbs[method]
+	switch {
+	case m.cond != nil:
+		trace("  matched %s %s with %s", method, t.pat, m.cond.key)
+	case ok:
+		trace("  matched %s %s", method, t.pat)
+	case t.anyMethod != nil:
+		trace("  matched %s for any method", t.pat)
+	case h.Mismatch == MismatchSafe404 && (method == "GET" || method == "HEAD"):
+		trace("  method %s not allowed (%s registered): 404", method, strings.Join(t.methods(), ", "))
+	default:
+		trace("  method %s not allowed (%s registered): 405", method, strings.Join(t.methods(), ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,364 @@
+package route
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaxVarLength(t *testing.T) {
+	h := &Handler{MaxVarLength: 5}
+	h.Get("/u/:id", ok("user"))
+	h.Get("/u/:id/posts", ok("posts"))
+	h.Get("/files/*path", ok("files"))
+	h.Get("/s/:slug", ok("slug"))
+	h.Get("/s/about-us", ok("about"))
+	for _, c := range []struct {
+		target string
+		code   int
+	}{
+		{"/u/12345", 200},
+		{"/u/123456", 414},
+		{"/u/123456/posts", 414},
+		{"/u/123456/nope", 404},
+		{"/u/12345/nope", 404},
+		{"/files/a/b", 200},
+		{"/files/abc/def", 414},
+		{"/s/about-us", 200},
+		{"/nothing/longvalue", 404},
+	} {
+		if w := do(h, "GET", c.target); w.Code != c.code {
+			t.Errorf("%s: got %d, want %d", c.target, w.Code, c.code)
+		}
+	}
+	h.Handle414 = ok("custom")
+	if w := do(h, "GET", "/u/123456"); w.Body.String() != "custom" {
+		t.Errorf("Handle414 wasn't called: %q", w.Body.String())
+	}
+
+}
+
+func TestHandleConstraintFail(t *testing.T) {
+	h := &Handler{}
+	var fails []string
+	h.HandleConstraintFail = func(w http.ResponseWriter, r *http.Request, varName, value string) {
+		fails = append(fails, varName+"="+value)
+		http.Error(w, "bad "+varName, 422)
+	}
+	h.GetInt("/items/:n", ":n", 1, 10, ok("item"))
+	h.GetInt("/items/:n/parts", ":n", 1, 10, ok("parts"))
+	h.Get("/other", ok("other"))
+	for _, c := range []struct {
+		target string
+		code   int
+	}{
+		{"/items/3", 200},
+		{"/items/3/parts", 200},
+		{"/items/11", 422},
+		{"/items/abc", 422},
+		{"/items/abc/parts", 422},
+		{"/items/abc/nope", 404},
+		{"/items/5/nope", 404},
+		{"/nothing", 404},
+	} {
+		if w := do(h, "GET", c.target); w.Code != c.code {
+			t.Errorf("%s: got %d, want %d", c.target, w.Code, c.code)
+		}
+	}
+	if got := strings.Join(fails, ","); got != ":n=11,:n=abc,:n=abc" {
+		t.Errorf("HandleConstraintFail got %s", got)
+	}
+
+	// Without the hook a rejected value is a 404.
+	h2 := &Handler{}
+	h2.GetInt("/items/:n", ":n", 1, 10, ok("item"))
+	if w := do(h2, "GET", "/items/11"); w.Code != 404 {
+		t.Errorf("without the hook got %d", w.Code)
+	}
+
+	// A pattern that matches anyway wins over the rejection.
+	h.Get("/*all", ok("all"))
+	if w := do(h, "GET", "/items/11"); w.Body.String() != "all" {
+		t.Errorf("got %q", w.Body.String())
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	h := &Handler{}
+	for _, p := range []string{
+		"/a/:x",
+		"/a/b/c",
+		"/a/:x/c",
+		"/a/b/*rest",
+		"/a/b/c/d/e",
+		"/a/:x/c/:y/e",
+		"/a/b/c/:y",
+		"/p/:x/q/r",
+		"/p/s/:y/t",
+	} {
+		h.Get(p, ok(p))
+	}
+	for _, c := range []struct {
+		target, pat string
+	}{
+		{"/a/b", "/a/:x"},
+		{"/a/z", "/a/:x"},
+		{"/a/b/c", "/a/b/c"},
+		{"/a/z/c", "/a/:x/c"},
+		{"/a/b/z", "/a/b/*rest"},
+		{"/a/b/c/z", "/a/b/c/:y"},
+		{"/a/b/c/z/y", "/a/b/*rest"},
+		{"/a/b/c/d", "/a/b/c/:y"},
+		{"/a/b/z/d", "/a/b/*rest"},
+		{"/a/b/c/d/e", "/a/b/c/d/e"},
+		{"/a/b/c/z/e", "/a/b/*rest"},
+		{"/a/z/c/d/e", "/a/:x/c/:y/e"},
+		{"/p/s/q/r", "/p/:x/q/r"},
+		{"/p/s/q/t", "/p/s/:y/t"},
+		{"/p/z/q/r", "/p/:x/q/r"},
+		{"/a/z/z", ""},
+		{"/p/s/q/z", ""},
+	} {
+		w := do(h, "GET", c.target)
+		if c.pat == "" {
+			if w.Code != 404 {
+				t.Errorf("%s: got %d %q, want 404", c.target, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if got := w.Body.String(); got != c.pat {
+			t.Errorf("%s: went to %q, want %q", c.target, got, c.pat)
+		}
+	}
+}
+
+func TestMaxVars(t *testing.T) {
+	h := &Handler{MaxVars: 3}
+	h.Get("/:a/:b/:c", ok("three"))
+	h.Get("/:a/:b/:c/:d", ok("four"))
+	h.Get("/:a/:b/:c/x/*rest", ok("rest"))
+	h.Get("/s/:a/:b/:c", ok("static"))
+	for target, code := range map[string]int{
+		"/1/2/3":       200,
+		"/1/2/3/4":     404,
+		"/1/2/3/x/y/z": 404,
+		"/s/1/2/3":     200,
+	} {
+		if w := do(h, "GET", target); w.Code != code {
+			t.Errorf("%s: got %d, want %d", target, w.Code, code)
+		}
+	}
+	h.MaxVars = 0
+	if w := do(h, "GET", "/1/2/3/4"); w.Code != 200 {
+		t.Errorf("unlimited got %d", w.Code)
+	}
+}
+
+func TestRootSuffix(t *testing.T) {
+	h := &Handler{}
+	h.Get("/", ok("index"))
+	h.Get("/about", ok("about"))
+	h.Get("/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("spa " + r.FormValue("*path")))
+	})
+	h.Get("/api/status", ok("status"))
+	h.Get("/api/users/:id", ok("user"))
+	h.Pst("/api/users", ok("create"))
+	for _, c := range []struct {
+		method, target string
+		code           int
+		body           string
+	}{
+		{"GET", "/", 200, "index"},
+		{"GET", "/about", 200, "about"},
+		{"GET", "/about/", 200, "about"},
+		{"GET", "/about/team", 200, "spa about/team"},
+		{"GET", "/dashboard", 200, "spa dashboard"},
+		{"GET", "/a/b/c", 200, "spa a/b/c"},
+		{"GET", "/api/status", 200, "status"},
+		{"GET", "/api/users/5", 200, "user"},
+		{"GET", "/api/users/5/x", 200, "spa api/users/5/x"},
+		{"GET", "/api", 200, "spa api"},
+		// The static pattern matches but not the method, so it's a 405
+		// rather than a fall back to the suffix.
+		{"GET", "/api/users", 405, ""},
+		{"POST", "/api/users", 200, "create"},
+		{"POST", "/dashboard", 405, ""},
+	} {
+		w := do(h, c.method, c.target)
+		if w.Code != c.code || c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", c.method, c.target, w.Code, w.Body.String(), c.code, c.body)
+		}
+	}
+	if !panics(func() { h.Get("/:page", ok("page")) }) {
+		t.Error("a variable next to the root suffix didn't conflict")
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:id", ok("user"))
+	h.Get("/users/me", ok("me"))
+	h.Get("/*path", ok("app"))
+	h.Get("/a/b/:x", ok("b"))
+	h.Get("/a/:y/c", ok("c"))
+	check := func(when string, want map[string]string) {
+		t.Helper()
+		for target, body := range want {
+			if w := do(h, "GET", target); w.Body.String() != body {
+				t.Errorf("%s, %s: went to %q, want %q", when, target, w.Body.String(), body)
+			}
+		}
+	}
+	check("by default", map[string]string{"/users/5": "user", "/users/me": "me", "/a/b/c": "b", "/x": "app"})
+
+	h.Prioritize("/*path", 1)
+	check("with the suffix first", map[string]string{"/users/5": "app", "/users/me": "app", "/a/b/c": "app", "/x": "app"})
+
+	h.Prioritize("/users/:id", 2)
+	h.Prioritize("/a/:y/c", 2)
+	check("with a variable first", map[string]string{"/users/5": "user", "/users/me": "user", "/a/b/c": "c", "/a/b/d": "app"})
+
+	// Ties fall back on the usual precedence.
+	h.Prioritize("/users/me", 2)
+	h.Prioritize("/a/b/:x", 2)
+	check("with ties", map[string]string{"/users/me": "me", "/users/5": "user", "/a/b/c": "b", "/x": "app"})
+
+	// Both forms of an optional variable get the priority.
+	h.Get("/items/:id?", ok("items"))
+	h.Prioritize("/items/:id?", 2)
+	check("with an optional variable", map[string]string{"/items": "items", "/items/5": "items"})
+
+	if !panics(func() { h.Prioritize("/nope", 1) }) {
+		t.Error("prioritizing an unknown pattern didn't panic")
+	}
+	if !panics(func() { h.Prioritize("/users", 1) }) {
+		t.Error("prioritizing a node without routes didn't panic")
+	}
+}
+
+func TestMostSpecific(t *testing.T) {
+	pats := []string{
+		"/a/b/*rest",
+		"/a/:x/c/d",
+		"/t/v/:b/:c/:d",
+		"/t/:a/w/*rest",
+		"/p/:x/q",
+		"/p/r/*rest",
+	}
+	for _, c := range []struct {
+		target, first, specific string
+	}{
+		// More static elements win.
+		{"/a/b/c/d", "/a/b/*rest", "/a/:x/c/d"},
+		{"/a/b/c/e", "/a/b/*rest", "/a/b/*rest"},
+		// Then fewer variables.
+		{"/t/v/w/x/y", "/t/v/:b/:c/:d", "/t/:a/w/*rest"},
+		// Then the usual precedence.
+		{"/p/r/q", "/p/r/*rest", "/p/r/*rest"},
+	} {
+		for _, specific := range []bool{false, true} {
+			h := &Handler{MostSpecific: specific}
+			for _, p := range pats {
+				h.Get(p, ok(p))
+			}
+			want := c.first
+			if specific {
+				want = c.specific
+			}
+			if got := do(h, "GET", c.target).Body.String(); got != want {
+				t.Errorf("%s with MostSpecific %v: went to %q, want %q", c.target, specific, got, want)
+			}
+		}
+	}
+
+	// Priorities are weighed first.
+	h := &Handler{MostSpecific: true}
+	for _, p := range pats {
+		h.Get(p, ok(p))
+	}
+	h.Prioritize("/a/b/*rest", 1)
+	if got := do(h, "GET", "/a/b/c/d").Body.String(); got != "/a/b/*rest" {
+		t.Errorf("the prioritized pattern lost to %q", got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:userID", ok("user"))
+	h.Put("/users/:userID", ok("put"))
+	h.Get("/users/me/settings", ok("settings"))
+	h.GetQuery("/callback", "code", ok("code"))
+	h.GetInt("/pages/:n", ":n", 1, 10, ok("page"))
+	for _, c := range []struct {
+		method, path, want string
+	}{
+		{"GET", "/users/5", `GET /users/5
+  "users" matched exactly
+  "5" captured as :userID
+  matched GET /users/:userID
+`},
+		{"POST", "/users/5", `POST /users/5
+  "users" matched exactly
+  "5" captured as :userID
+  method POST not allowed (GET, PUT registered): 405
+`},
+		{"GET", "/users/me", `GET /users/me
+  "users" matched exactly
+  "me" matched exactly
+  no pattern ends here
+  backing up from "me"
+  "me" captured as :userID
+  matched GET /users/:userID
+`},
+		{"GET", "/nope/x", `GET /nope/x
+  "nope" matched nothing at depth 1
+  no pattern matches: 404
+`},
+		{"GET", "/users/5/x", `GET /users/5/x
+  "users" matched exactly
+  "5" captured as :userID
+  "x" matched nothing at depth 3
+  backing up from :userID
+  backing up from "users"
+  "users" matched nothing at depth 1
+  no pattern matches: 404
+`},
+		{"GET", "/callback?code=abc", `GET /callback?code=abc
+  "callback" matched exactly
+  matched GET /callback with query parameter code
+`},
+		{"GET", "/callback", `GET /callback
+  "callback" matched exactly
+  GET /callback requires query parameter code
+  no conditions met
+  backing up from "callback"
+  "callback" matched nothing at depth 1
+  no pattern matches: 404
+`},
+		{"GET", "/pages/11", `GET /pages/11
+  "pages" matched exactly
+  "11" is not an int between 1 and 10 for :n
+  backing up from "pages"
+  "pages" matched nothing at depth 1
+  no pattern matches: 404
+`},
+	} {
+		if got := h.Explain(c.method, c.path); got != c.want {
+			t.Errorf("Explain(%s, %s) =\n%swant\n%s", c.method, c.path, got, c.want)
+		}
+	}
+	h.MaxURLLength = 12
+	if got := h.Explain("GET", "/users/12345"); !strings.HasSuffix(got, "  matched GET /users/:userID\n") {
+		t.Errorf("a URI at the limit got\n%s", got)
+	}
+	if got, want := h.Explain("GET", "/users/123456"), "GET /users/123456\n  URI is 13 bytes long: 414\n"; got != want {
+		t.Errorf("a URI over the limit got\n%swant\n%s", got, want)
+	}
+	h.MaxURLLength = 0
+
+	h.HandleConstraintFail = func(http.ResponseWriter, *http.Request, string, string) {}
+	if got := h.Explain("GET", "/pages/11"); !strings.HasSuffix(got, "  no pattern matches without :n=\"11\": constraint failure\n") {
+		t.Errorf("with HandleConstraintFail got\n%s", got)
+	}
+}
@@ -0,0 +1,186 @@
+package route
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequireAccept returns middleware that responds with 406 Not
+// Acceptable unless the request's Accept header allows at least one of
+// the given media types, e.g. "application/json". A missing Accept
+// header accepts anything. Quality values are honored, so a type
+// excluded with q=0 is not acceptable even if "*/*" is present.
+func RequireAccept(types ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			accept := r.Header.Values("Accept")
+			if len(accept) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ranges := parseAccept(strings.Join(accept, ","))
+			for _, t := range types {
+				if acceptQuality(ranges, t) > 0 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "406 not acceptable", 406)
+		})
+	}
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(s string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(s, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		i := strings.Index(mt, "/")
+		if i <= 0 || i == len(mt)-1 {
+			continue
+		}
+		mr := mediaRange{typ: mt[:i], subtype: mt[i+1:], q: 1}
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.ToLower(strings.TrimSpace(k)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			mr.q = q
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality the ranges give to media type t,
+// using the most specific matching range.
+func acceptQuality(ranges []mediaRange, t string) float64 {
+	t, _, _ = strings.Cut(strings.ToLower(t), ";")
+	typ, subtype, _ := strings.Cut(strings.TrimSpace(t), "/")
+	q, best := 0.0, -1
+	for _, mr := range ranges {
+		specificity := -1
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			specificity = 2
+		case mr.typ == typ && mr.subtype == "*":
+			specificity = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			specificity = 0
+		}
+		if specificity > best {
+			q, best = mr.q, specificity
+		}
+	}
+	return q
+}
+
+// RequireHTTPS returns middleware that redirects requests that didn't
+// come over HTTPS to the same URL with the https scheme. GET and HEAD
+// requests get a 301; others get a 308 so that the method and body are
+// kept. Behind a proxy that terminates TLS, set trustForwarded to
+// believe the proxy's X-Forwarded-Proto header; only do so if clients
+// can't reach the server without going through the proxy. Requests
+// without a host to redirect to get a 403.
+func RequireHTTPS(trustForwarded bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if trustForwarded {
+				proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+				if strings.EqualFold(strings.TrimSpace(proto), "https") {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if r.Host == "" {
+				http.Error(w, "403 https required", 403)
+				return
+			}
+			u := *r.URL
+			u.Scheme, u.Host = "https", r.Host
+			u.RawQuery = StripVars(u.RawQuery)
+			code := 308
+			if r.Method == "GET" || r.Method == "HEAD" {
+				code = 301
+			}
+			http.Redirect(w, r, u.String(), code)
+		})
+	}
+}
+
+// Shed returns middleware that responds with 503 Service Unavailable,
+// without calling the handler, whenever isOverloaded returns true. The
+// signal can be anything cheap to check, like a queue depth or CPU
+// load. If retryAfter is positive, it is sent as the Retry-After
+// header, rounded up to whole seconds, to tell clients when to come
+// back. Put it as far out in the chain as possible so that shed
+// requests cost little.
+func Shed(isOverloaded func() bool, retryAfter time.Duration) func(http.Handler) http.Handler {
+	secs := strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isOverloaded() {
+				if retryAfter > 0 {
+					w.Header().Set("Retry-After", secs)
+				}
+				http.Error(w, "503 service unavailable", 503)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Chain wraps f in the given middleware, the first outermost, for
+// routes that need middleware of their own:
+//
+//	h.Get("/admin", route.Chain(GetAdmin, route.Named("auth", RequireAuth)))
+func Chain(f http.HandlerFunc, mw ...func(http.Handler) http.Handler) http.HandlerFunc {
+	var h http.Handler = f
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h.ServeHTTP
+}
+
+type appliedKey struct{}
+
+// Named returns mw under a name, which AppliedMiddleware reports for
+// the requests that pass through it. Naming middleware is optional;
+// unnamed middleware works as usual but isn't reported.
+func Named(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			names, _ := r.Context().Value(appliedKey{}).([]string)
+			names = append(names[:len(names):len(names)], name)
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), appliedKey{}, names)))
+		})
+	}
+}
+
+// AppliedMiddleware returns the names of the Named middleware the
+// request has entered so far, outermost first, so a handler sees all
+// the named middleware in front of it. It's for diagnosing why a
+// request was or wasn't subject to some processing.
+func AppliedMiddleware(r *http.Request) []string {
+	names, _ := r.Context().Value(appliedKey{}).([]string)
+	return append([]string{}, names...)
+}
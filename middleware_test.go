@@ -0,0 +1,142 @@
+package route
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireAccept(t *testing.T) {
+	h := RequireAccept("application/json")(ok("json"))
+	for _, c := range []struct {
+		accept string
+		code   int
+	}{
+		{"", 200},
+		{"application/json", 200},
+		{"text/html, application/json;q=0.5", 200},
+		{"*/*", 200},
+		{"application/*", 200},
+		{"text/html", 406},
+		{"text/*, image/png", 406},
+		{"application/json;q=0, */*", 406},
+		{"*/*;q=0", 406},
+		{"Application/JSON", 200},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("Accept %q: got %d, want %d", c.accept, w.Code, c.code)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary is %q", c.accept, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	for _, c := range []struct {
+		trust          bool
+		method, target string
+		tls            bool
+		proto          string
+		code           int
+		location       string
+	}{
+		{false, "GET", "http://example.com/a?b=c", true, "", 200, ""},
+		{false, "GET", "http://example.com/a?b=c", false, "", 301, "https://example.com/a?b=c"},
+		{false, "HEAD", "http://example.com/a", false, "", 301, "https://example.com/a"},
+		{false, "POST", "http://example.com:8080/a", false, "", 308, "https://example.com:8080/a"},
+		{false, "GET", "http://example.com/a", false, "https", 301, "https://example.com/a"},
+		{true, "GET", "http://example.com/a", false, "https", 200, ""},
+		{true, "GET", "http://example.com/a", false, "HTTPS, http", 200, ""},
+		{true, "GET", "http://example.com/a", false, "http", 301, "https://example.com/a"},
+		{true, "PUT", "http://example.com/a", false, "", 308, "https://example.com/a"},
+	} {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		if c.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if c.proto != "" {
+			r.Header.Set("X-Forwarded-Proto", c.proto)
+		}
+		w := httptest.NewRecorder()
+		RequireHTTPS(c.trust)(ok("ok")).ServeHTTP(w, r)
+		if w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Errorf("%+v: got %d to %q", c, w.Code, w.Header().Get("Location"))
+		}
+	}
+	// The variables a Handler added aren't part of the redirect.
+	h := &Handler{}
+	h.Get("/u/:id", Chain(ok("ok"), RequireHTTPS(false)))
+	w := do(h, "GET", "http://example.com/u/1?q=2")
+	if loc := w.Header().Get("Location"); loc != "https://example.com/u/1?q=2" {
+		t.Errorf("redirected to %q", loc)
+	}
+
+	r := httptest.NewRequest("GET", "/a", nil)
+	r.Host = ""
+	w = httptest.NewRecorder()
+	if RequireHTTPS(false)(ok("ok")).ServeHTTP(w, r); w.Code != 403 {
+		t.Errorf("without a host got %d", w.Code)
+	}
+}
+
+func TestShed(t *testing.T) {
+	overloaded := false
+	h := &Handler{}
+	h.Get("/expensive", Chain(ok("done"), Shed(func() bool { return overloaded }, 1500*time.Millisecond)))
+	h.Get("/quiet", Chain(ok("done"), Shed(func() bool { return overloaded }, 0)))
+	h.Get("/cheap", ok("cheap"))
+	for _, o := range []bool{false, true, false} {
+		overloaded = o
+		w := do(h, "GET", "/expensive")
+		switch {
+		case o && (w.Code != 503 || w.Header().Get("Retry-After") != "2"):
+			t.Errorf("overloaded got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+		case !o && (w.Code != 200 || w.Body.String() != "done"):
+			t.Errorf("not overloaded got %d %q", w.Code, w.Body.String())
+		}
+		if w := do(h, "GET", "/quiet"); o && (w.Code != 503 || w.Header().Get("Retry-After") != "") {
+			t.Errorf("without a retry delay got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+		}
+		if w := do(h, "GET", "/cheap"); w.Code != 200 {
+			t.Errorf("a route without Shed got %d", w.Code)
+		}
+	}
+}
+
+func TestAppliedMiddleware(t *testing.T) {
+	var seen []string
+	pass := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = AppliedMiddleware(r)
+			next.ServeHTTP(w, r)
+		})
+	}
+	show := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(AppliedMiddleware(r), ",")))
+	}
+	h := &Handler{}
+	h.Get("/admin", Chain(show, Named("auth", pass), pass, Named("audit", pass)))
+	h.Get("/public", Chain(show, pass))
+	if got := do(h, "GET", "/admin").Body.String(); got != "auth,audit" {
+		t.Errorf("/admin applied %q", got)
+	}
+	if got := strings.Join(seen, ","); got != "auth,audit" {
+		t.Errorf("the innermost middleware saw %q", got)
+	}
+	if got := do(h, "GET", "/public").Body.String(); got != "" {
+		t.Errorf("/public applied %q", got)
+	}
+	if got := do(h, "GET", "/admin").Body.String(); got != "auth,audit" {
+		t.Errorf("the second request applied %q", got)
+	}
+}
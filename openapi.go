@@ -0,0 +1,108 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// OpenAPIInfo is the info object of an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIDoc struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    OpenAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	OperationID string                     `json:"operationId,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string            `json:"name"`
+	In          string            `json:"in"`
+	Required    bool              `json:"required"`
+	Description string            `json:"description,omitempty"`
+	Schema      map[string]string `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// openAPIMethods are the methods OpenAPI has operations for.
+var openAPIMethods = map[string]string{
+	"GET": "get", "PUT": "put", "POST": "post", "DELETE": "delete",
+	"OPTIONS": "options", "HEAD": "head", "PATCH": "patch", "TRACE": "trace",
+}
+
+// OpenAPI generates an OpenAPI document for the routes on the
+// DefaultHandler.
+func OpenAPI(info OpenAPIInfo) ([]byte, error) {
+	return DefaultHandler.OpenAPI(info)
+}
+
+// OpenAPI generates a minimal OpenAPI 3.0 document in JSON describing
+// the registered routes: their paths, methods, and path parameters,
+// along with anything given to Describe. Variables become path
+// parameters, so "/users/:userID" is documented as "/users/{userID}".
+// Named patterns give their operations IDs like "post.get"; since
+// operation IDs must be unique, only the first of the two paths of a
+// pattern with an optional variable gets one. Request and response
+// schemas are left for you to fill in, and routes for methods OpenAPI
+// doesn't know, or for any method, are left out.
+func (h *Handler) OpenAPI(info OpenAPIInfo) ([]byte, error) {
+	if info.Title == "" || info.Version == "" {
+		return nil, errors.New("route: OpenAPI info needs a title and a version")
+	}
+	doc := openAPIDoc{OpenAPI: "3.0.3", Info: info, Paths: map[string]map[string]openAPIOperation{}}
+	ids := map[string]bool{}
+	for _, ri := range h.Routes() {
+		m, ok := openAPIMethods[ri.Method]
+		if !ok {
+			continue
+		}
+		parts := splitPath(ri.Pattern)
+		params := []openAPIParameter{}
+		for i, part := range parts {
+			if part[0] != ':' && part[0] != '*' {
+				continue
+			}
+			p := openAPIParameter{Name: part[1:], In: "path", Required: true, Schema: map[string]string{"type": "string"}}
+			if ri.Doc != nil {
+				p.Description = ri.Doc.Params[part]
+			}
+			params = append(params, p)
+			parts[i] = "{" + part[1:] + "}"
+		}
+		p := "/" + strings.Join(parts, "/")
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = map[string]openAPIOperation{}
+		}
+		if _, ok := doc.Paths[p][m]; ok {
+			// Conditional routes share an operation.
+			continue
+		}
+		op := openAPIOperation{
+			Parameters: params,
+			Responses:  map[string]openAPIResponse{"default": {Description: "Default response"}},
+		}
+		if ri.Doc != nil {
+			op.Summary = ri.Doc.Summary
+		}
+		if id := ri.Name + "." + m; ri.Name != "" && !ids[id] {
+			op.OperationID = id
+			ids[id] = true
+		}
+		doc.Paths[p][m] = op
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,59 @@
+package route
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:userID", ok("user"), "user")
+	h.Del("/users/:userID", ok("del"))
+	h.Get("/static/*path", ok("static"))
+	h.Match("PROPFIND", "/dav", ok("dav"))
+	h.AnyMethodVar("/proxy", ok("proxy"))
+	h.Describe("GET", "/users/:userID", RouteDoc{Summary: "Gets a user.", Params: map[string]string{":userID": "The user's ID."}})
+	b, err := h.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+		"openapi": "3.0.3",
+		"info": {"title": "Test", "version": "1.0"},
+		"paths": {
+			"/users/{userID}": {
+				"get": {
+					"summary": "Gets a user.",
+					"operationId": "user.get",
+					"parameters": [{"name": "userID", "in": "path", "required": true, "description": "The user's ID.", "schema": {"type": "string"}}],
+					"responses": {"default": {"description": "Default response"}}
+				},
+				"delete": {
+					"operationId": "user.delete",
+					"parameters": [{"name": "userID", "in": "path", "required": true, "schema": {"type": "string"}}],
+					"responses": {"default": {"description": "Default response"}}
+				}
+			},
+			"/static/{path}": {
+				"get": {
+					"parameters": [{"name": "path", "in": "path", "required": true, "schema": {"type": "string"}}],
+					"responses": {"default": {"description": "Default response"}}
+				}
+			}
+		}
+	}`
+	var got, exp interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &exp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %s", b)
+	}
+	if _, err := h.OpenAPI(OpenAPIInfo{Title: "Test"}); err == nil {
+		t.Error("a missing version wasn't an error")
+	}
+}
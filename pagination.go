@@ -0,0 +1,35 @@
+package route
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PaginationLinks sets a Link header (RFC 8288) on w pointing at the
+// first, previous, next, and last pages of a paginated list, where
+// page is the current page and total the number of pages, counting
+// from 1. The links are built from the named pattern, filled in with
+// args as by URL, plus the request's query with its "page" parameter
+// set. The first page has no previous link and the last no next one.
+func PaginationLinks(h *Handler, w http.ResponseWriter, r *http.Request, name string, page, total int, args ...string) {
+	if total < 1 {
+		return
+	}
+	base := h.URL(name, args...)
+	q, _ := url.ParseQuery(StripVars(r.URL.RawQuery))
+	link := func(p int, rel string) string {
+		q.Set("page", strconv.Itoa(p))
+		return "<" + base + "?" + q.Encode() + `>; rel="` + rel + `"`
+	}
+	links := []string{link(1, "first")}
+	if page > 1 {
+		links = append(links, link(page-1, "prev"))
+	}
+	if page < total {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(total, "last"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
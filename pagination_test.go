@@ -0,0 +1,31 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestPaginationLinks(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:id/posts", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.FormValue("page"))
+		PaginationLinks(h, w, r, "posts", page, 3, r.FormValue(":id"))
+	}, "posts")
+	for target, want := range map[string]string{
+		"/users/5/posts?page=1":          `</users/5/posts?page=1>; rel="first", </users/5/posts?page=2>; rel="next", </users/5/posts?page=3>; rel="last"`,
+		"/users/5/posts?page=2":          `</users/5/posts?page=1>; rel="first", </users/5/posts?page=1>; rel="prev", </users/5/posts?page=3>; rel="next", </users/5/posts?page=3>; rel="last"`,
+		"/users/5/posts?page=3&sort=new": `</users/5/posts?page=1&sort=new>; rel="first", </users/5/posts?page=2&sort=new>; rel="prev", </users/5/posts?page=3&sort=new>; rel="last"`,
+	} {
+		if got := do(h, "GET", target).Header().Get("Link"); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", target, got, want)
+		}
+	}
+
+	h.Get("/empty", func(w http.ResponseWriter, r *http.Request) {
+		PaginationLinks(h, w, r, "posts", 1, 0, "5")
+	})
+	if got := do(h, "GET", "/empty").Header().Get("Link"); got != "" {
+		t.Errorf("no pages got %s", got)
+	}
+}
@@ -0,0 +1,130 @@
+package route
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit returns middleware that limits each client IP to rps
+// requests per second on average, with bursts of up to burst requests.
+// See RateLimiter for the details, and for limiting by something other
+// than IP.
+func RateLimit(rps float64, burst int) func(http.Handler) http.Handler {
+	return (&RateLimiter{Rate: rps, Burst: burst}).Limit
+}
+
+// A RateLimiter limits requests with a token bucket per key: each
+// request takes a token from its key's bucket, which holds up to Burst
+// tokens and gets Rate more every second. A request finding the bucket
+// empty gets a 429 Too Many Requests, with a Retry-After header saying
+// when there will be a token, and the handler isn't called. Since the
+// buckets belong to the RateLimiter, middleware sharing one shares the
+// limits, and wrapping only some routes limits only those:
+//
+//	limit := (&route.RateLimiter{Rate: 1, Burst: 5, Key: route.HeaderKey("X-API-Key")}).Limit
+//	h.Pst("/reports", route.Chain(PostReport, limit))
+//
+// Buckets that have filled up again are forgotten, so memory only grows
+// with the number of recently active keys. A RateLimiter must not be
+// copied or have its fields changed once it's in use.
+type RateLimiter struct {
+	Rate  float64 // Tokens added per second; must be positive.
+	Burst int     // The most tokens a bucket holds; less than 1 means 1.
+
+	// Key returns the key of the bucket for a request, by default the
+	// host of its RemoteAddr. Behind a proxy every request seems to
+	// come from the proxy, so key on something the proxy sets instead.
+	Key func(*http.Request) string
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // For tests; time.Now if nil.
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time // When tokens was last brought up to date.
+}
+
+// HeaderKey returns a Key function for RateLimiter that uses the value
+// of the named header, e.g. an API key, so requests without it share a
+// bucket.
+func HeaderKey(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Limit is the middleware; it panics unless Rate is positive.
+func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
+	if !(rl.Rate > 0) {
+		panic("route: rate limit must be positive")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := rl.take(rl.key(r)); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "429 too many requests", 429)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) key(r *http.Request) string {
+	if rl.Key != nil {
+		return rl.Key(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// take takes a token from the key's bucket, or returns how long until
+// there is one.
+func (rl *RateLimiter) take(key string) time.Duration {
+	now := time.Now()
+	if rl.now != nil {
+		now = rl.now()
+	}
+	burst := float64(rl.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.buckets == nil {
+		rl.buckets = map[string]*bucket{}
+	}
+	// Once a bucket has filled up, forgetting it changes nothing. Not
+	// looking more than once a minute keeps the sweeps cheap.
+	full := time.Duration(burst / rl.Rate * float64(time.Second))
+	if now.Sub(rl.lastSweep) >= full && now.Sub(rl.lastSweep) >= time.Minute {
+		for k, b := range rl.buckets {
+			if now.Sub(b.last) >= full {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+	b := rl.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: burst, last: now}
+		rl.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()*rl.Rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rl.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
@@ -0,0 +1,101 @@
+package route
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	rl := &RateLimiter{Rate: 2, Burst: 3, now: func() time.Time { return now }}
+	h := &Handler{}
+	h.Get("/cheap", ok("cheap"))
+	h.Get("/expensive", Chain(ok("expensive"), rl.Limit))
+	serve := func(target, addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// A burst is allowed, then the client has to wait.
+	for i := 0; i < 3; i++ {
+		if w := serve("/expensive", "1.2.3.4:5"); w.Code != 200 {
+			t.Fatalf("request %d of the burst got %d", i, w.Code)
+		}
+	}
+	w := serve("/expensive", "1.2.3.4:6")
+	if w.Code != 429 || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("over the burst got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve("/expensive", "5.6.7.8:5"); w.Code != 200 {
+		t.Errorf("another client got %d", w.Code)
+	}
+	for i := 0; i < 10; i++ {
+		if w := serve("/cheap", "1.2.3.4:5"); w.Code != 200 {
+			t.Fatalf("unlimited route got %d", w.Code)
+		}
+	}
+
+	// Tokens come back at the rate.
+	now = now.Add(500 * time.Millisecond)
+	if w := serve("/expensive", "1.2.3.4:5"); w.Code != 200 {
+		t.Errorf("after a refill got %d", w.Code)
+	}
+	if w := serve("/expensive", "1.2.3.4:5"); w.Code != 429 {
+		t.Errorf("after using the refill got %d", w.Code)
+	}
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		if w := serve("/expensive", "1.2.3.4:5"); w.Code != 200 {
+			t.Errorf("request %d after refilling got %d", i, w.Code)
+		}
+	}
+	if w := serve("/expensive", "1.2.3.4:5"); w.Code != 429 {
+		t.Errorf("the bucket held more than the burst: %d", w.Code)
+	}
+
+	// Buckets that have filled up are forgotten.
+	now = now.Add(time.Hour)
+	serve("/expensive", "9.9.9.9:1")
+	if n := len(rl.buckets); n != 1 {
+		t.Errorf("%d buckets left after sweeping, want 1", n)
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	rl := &RateLimiter{Rate: 0.1, now: func() time.Time { return now }}
+	f := rl.Limit(ok("x"))
+	if w := do(f, "GET", "/"); w.Code != 200 {
+		t.Fatalf("got %d", w.Code)
+	}
+	now = now.Add(2 * time.Second)
+	w := do(f, "GET", "/")
+	if w.Code != 429 || w.Header().Get("Retry-After") != "8" {
+		t.Errorf("got %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	rl := &RateLimiter{Rate: 1, Burst: 1, Key: HeaderKey("X-API-Key")}
+	f := rl.Limit(ok("x"))
+	serve := func(key string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, r)
+		return w.Code
+	}
+	if a1, a2, b := serve("a"), serve("a"), serve("b"); a1 != 200 || a2 != 429 || b != 200 {
+		t.Errorf("got %d %d %d", a1, a2, b)
+	}
+	if w := do(RateLimit(100, 1)(ok("y")), "GET", "/"); w.Code != 200 {
+		t.Errorf("RateLimit got %d", w.Code)
+	}
+	if !panics(func() { (&RateLimiter{}).Limit(ok("z")) }) {
+		t.Error("a zero rate didn't panic")
+	}
+}
@@ -20,15 +20,28 @@
 //   route.Get("/static/*filepath/foo", GetStaticFoo)    // panics
 //
 // Captured variables are appended to the request URL's query making
-// them accessible via the request's FormValue method.
+// them accessible via the request's FormValue method. Parameters the
+// client sent with names like variables are dropped, so they can't be
+// spoofed.
 //
 //   id := req.FormValue(":userID")
 //   fp := req.FormValue("*filepath")
 //
+// They are also available all at once, either decoded or exactly as
+// they appeared in the path:
+//
+//   vars := route.Vars(req)
+//   raw := route.RawVars(req)
+//
 // You can get back the original query string if you need it:
 //
 //   q := route.StripVars(req.URL.RawQuery)
 //
+// The last element of a pattern can be an optional variable, in which
+// case the pattern also matches without it.
+//
+//   route.Get("/items/:itemID?", GetItems) // matches "/items" and "/items/123"
+//
 // Get, Put, and the others, panic if the pattern conflicts with
 // another one.
 //
@@ -36,6 +49,17 @@
 //   route.Get("/*path", GetAnythingButRoot)
 //   route.Get("/:foo", GetFoo)            // panics
 //
+// Static elements are preferred over variables. If a static element
+// matches but nothing registered below it does, the variable is tried
+// instead, so a suffix variable at the root can serve everything the
+// other routes don't. The same goes at any depth: when several
+// patterns match, the winner is the one with a static element at the
+// first element where they differ.
+//
+//   route.Get("/", GetIndex)
+//   route.Get("/about", GetAbout)
+//   route.Get("/*path", GetApp)   // matches "/about/team" but not "/about"
+//
 // Routes can optionally be named, that way you can construct a url
 // that would match the route.
 //
@@ -43,10 +67,11 @@
 //   ...
 //   http.Redirect(w, r, route.URL("post", userID, postID), 303)
 //
-// There are hooks for 404 and 405 errors that would normally be
+// There are hooks for 404, 405, and 414 errors that would normally be
 // handled by the router, that way you can serve what ever you
 // want. The "Allow" header is added on 405 errors before calling your
-// handler.
+// handler. 414 errors only happen if you set a MaxURLLength or a
+// MaxVarLength.
 //
 //   route.Handle404(func(w http.ResponseWriter, r *http.Request) {
 //     w.Header().Set("Content-Type", "application/json")
@@ -82,31 +107,104 @@
 //
 //   log.Fatal(http.ListenAndServe(":8080", route.DefaultHandler))
 //
-// Lastly, there is no locking, so set up all your routes once and
-// hand it off to the http server.
+// Lastly, there is no locking when registering routes, so set up all
+// your routes once and hand it off to the http server. If the routes
+// have to change while serving, build a whole new set with Reload.
 //
 package route
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 var DefaultHandler = &Handler{}
 
+// WebDAVMethods are the methods defined by WebDAV (RFC 4918) on top
+// of the usual HTTP ones. It is meant to be passed to Methods.
+var WebDAVMethods = []string{"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"}
+
 // Match registers a pattern with the given method on the
 // DefaultHandler with an optional name.
 func Match(method, pat string, f http.HandlerFunc, name ...string) {
 	DefaultHandler.Match(method, pat, f, name...)
 }
 
+// Methods registers a pattern with each of the given methods on the
+// DefaultHandler with an optional name.
+func Methods(methods []string, pat string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.Methods(methods, pat, f, name...)
+}
+
+// Prioritize gives a pattern registered on the DefaultHandler a
+// priority.
+func Prioritize(pat string, priority int) {
+	DefaultHandler.Prioritize(pat, priority)
+}
+
+// RawSuffix registers a pattern whose suffix variable captures the
+// raw request path on the DefaultHandler with an optional name.
+func RawSuffix(method, pat string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.RawSuffix(method, pat, f, name...)
+}
+
+// GetQuery registers a pattern with method "GET" that requires a query
+// parameter on the DefaultHandler with an optional name.
+func GetQuery(pat, param string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.GetQuery(pat, param, f, name...)
+}
+
+// GetUntil registers a pattern with method "GET" on the DefaultHandler
+// that stops matching at expiry.
+func GetUntil(pat string, expiry time.Time, f http.HandlerFunc, name ...string) {
+	DefaultHandler.GetUntil(pat, expiry, f, name...)
+}
+
+// GetInternal registers a pattern with method "GET" on the
+// DefaultHandler that only internal requests can reach.
+func GetInternal(pat string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.GetInternal(pat, f, name...)
+}
+
+// GetHeader registers a pattern with method "GET" that requires a
+// header value on the DefaultHandler with an optional name.
+func GetHeader(pat, headerName, headerValue string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.GetHeader(pat, headerName, headerValue, f, name...)
+}
+
+// AnyMethodVar registers a pattern that matches any method on the
+// DefaultHandler with an optional name.
+func AnyMethodVar(pat string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.AnyMethodVar(pat, f, name...)
+}
+
+// GetInt registers a pattern with method "GET" whose variable must be
+// an integer in the given range on the DefaultHandler.
+func GetInt(pat, varName string, min, max int, f http.HandlerFunc, name ...string) {
+	DefaultHandler.GetInt(pat, varName, min, max, f, name...)
+}
+
 // Get registers a pattern with method "GET" on the DefaultHandler.
 func Get(pat string, f http.HandlerFunc, name ...string) {
 	DefaultHandler.Get(pat, f, name...)
 }
 
+// GetMulti registers several patterns with method "GET" on the
+// DefaultHandler with an optional name for the first.
+func GetMulti(pats []string, f http.HandlerFunc, name ...string) {
+	DefaultHandler.GetMulti(pats, f, name...)
+}
+
 // Pst registers a pattern with method "POST" on the DefaultHandler.
 func Pst(pat string, f http.HandlerFunc, name ...string) {
 	DefaultHandler.Pst(pat, f, name...)
@@ -136,139 +234,638 @@ func Handle405(f http.HandlerFunc) {
 	DefaultHandler.Handle405 = f
 }
 
+func Handle414(f http.HandlerFunc) {
+	DefaultHandler.Handle414 = f
+}
+
 func HandlePanic(f func(*http.Request, interface{})) {
 	DefaultHandler.HandlePanic = f
 }
 
 // URL constructs a url that would match the named pattern. Variables
-// must be provided in the same order as they appear in the pattern.
+// must be provided in the same order as they appear in the pattern,
+// though an optional one can be left out.
 func URL(name string, args ...string) string {
 	return DefaultHandler.URL(name, args...)
 }
 
+// RemovePrefix removes every route under the given prefix from the
+// DefaultHandler and returns the number of routes removed.
+func RemovePrefix(prefix string) int {
+	return DefaultHandler.RemovePrefix(prefix)
+}
+
 // StripVars removes any variables that were added to the query by the
-// Handler.
+// Handler. For any query q, and any variables appended to it by the
+// Handler, StripVars gives back q exactly. That holds because the
+// Handler drops parameters named like variables (that is, starting with
+// ':' or '*') from the query the client sent; otherwise a client could
+// set a variable the pattern didn't capture, or shadow one it did.
 func StripVars(q string) string {
-	colon := url.QueryEscape(":")
-	star := url.QueryEscape("*")
-	for {
+	for q != "" {
 		i := strings.LastIndex(q, "&")
-		if i == -1 {
-			if len(q) >= len(colon) && q[:len(colon)] == colon {
-				return ""
-			}
-			if len(q) >= len(star) && q[:len(star)] == star {
-				return ""
-			}
+		if !isVar(q[i+1:]) {
 			return q
 		}
-		if len(q[i+1:]) >= len(colon) && q[i+1:i+1+len(colon)] == colon {
-			q = q[:i]
-			continue
-		}
-		if len(q[i+1:]) >= len(star) && q[i+1:i+1+len(star)] == star {
-			q = q[:i]
-			continue
+		if i == -1 {
+			return ""
 		}
+		q = q[:i]
+	}
+	return q
+}
+
+// removeVars removes every parameter in the query q that is named like
+// a variable.
+func removeVars(q string) string {
+	if q == "" {
 		return q
 	}
+	params := strings.Split(q, "&")
+	kept := params[:0]
+	for _, p := range params {
+		if !isVar(p) {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// isVar reports whether the query parameter p, in its encoded "key=value"
+// form, is named like a variable.
+func isVar(p string) bool {
+	k, _, _ := strings.Cut(p, "=")
+	k, err := url.QueryUnescape(k)
+	return err == nil && k != "" && (k[0] == ':' || k[0] == '*')
+}
+
+// CleanRequest returns a copy of r without the variables the Handler
+// added to its query, for middleware that logs or forwards requests
+// and wants the URL the client sent. If r's form has been parsed, the
+// variables are removed from the copy's form as well. r itself is left
+// alone, so the handler can still read the variables.
+func CleanRequest(r *http.Request) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.URL.RawQuery = StripVars(r.URL.RawQuery)
+	if r2.Form != nil {
+		for k := range r2.Form {
+			if k != "" && (k[0] == ':' || k[0] == '*') {
+				delete(r2.Form, k)
+			}
+		}
+	}
+	return r2
 }
 
 type Handler struct {
 	Handle404   http.HandlerFunc
 	Handle405   http.HandlerFunc
+	Handle414   http.HandlerFunc                 // For requests over MaxURLLength or MaxVarLength.
 	HandlePanic func(*http.Request, interface{}) // Takes the value that was passed to the panic.
 
-	trie trie
-	pats map[string]string
+	// HandleConstraintFail, if set, is called instead of the 404
+	// handler when no pattern matches only because a constraint, like
+	// GetInt's, rejected a variable's value. It gets the first such
+	// variable and value, so an API can respond with, say, a 422 that
+	// names the bad parameter.
+	HandleConstraintFail func(w http.ResponseWriter, r *http.Request, varName, value string)
+
+	// Domain, if set, is the domain the Handler serves subdomains
+	// of, e.g. "example.com". The subdomain of each request is then
+	// available through Subdomain, so one set of routes can serve
+	// every tenant. Hosts outside the domain are routed as usual.
+	Domain string
+
+	// NormalizeUnicode puts request paths, and the patterns
+	// registered after it is set, in Unicode normalization form C
+	// before matching, so that "é" matches whether it was sent as one
+	// code point or as "e" and a combining accent. Captured variables
+	// are normalized too. It uses golang.org/x/text/unicode/norm.
+	NormalizeUnicode bool
+
+	// CORS, if set, answers CORS preflight requests to every pattern
+	// that doesn't have its own config from Preflight. Other OPTIONS
+	// requests are routed as usual.
+	CORS *CORSConfig
+
+	// Mismatch decides what happens when a pattern matches but the
+	// method doesn't. The default is to always respond with 405.
+	Mismatch MismatchPolicy
+
+	// InternalCheck decides whether a request may reach patterns
+	// registered with GetInternal. By default it's whether the
+	// request's RemoteAddr is a loopback or private address. Behind a
+	// proxy every request seems to come from the proxy, so set it to
+	// check something the proxy or the network vouches for instead.
+	InternalCheck func(*http.Request) bool
+
+	// MostSpecific changes which of the patterns that match a request
+	// wins. Instead of the one with a static element where the others
+	// have a variable first, it's the one with the most static
+	// elements; a tie goes to the one with fewer variables, and one
+	// still tied to the usual precedence. So for "/a/b/c/d",
+	// "/a/:x/c/d" wins over "/a/b/*rest". Priorities are weighed
+	// before specificity. Every candidate has to be considered for
+	// every request.
+	MostSpecific bool
+
+	// VarEncoder, if set, encodes each captured variable for the
+	// query, given its name, e.g. ":userID", and decoded value. The
+	// default escapes both with url.QueryEscape. It is for code that
+	// reads the raw query and expects some other format, like
+	// unescaped keys. The key must still start with the name's ':' or
+	// '*', escaped or not, so that StripVars and the dropping of
+	// spoofed parameters recognize it, and neither may contain '&'.
+	// Vars and RawVars don't depend on it.
+	VarEncoder func(name, value string) (key, encodedValue string)
+
+	// MaxRoutes, if positive, is the most routes that can be
+	// registered, counting each method, condition, and form of an
+	// optional variable separately. Registering more is an error, so
+	// Match and the like panic and RegisterAll returns the error. It
+	// guards against runaway registration when routes are added
+	// dynamically, e.g. by tenants.
+	MaxRoutes int
+
+	// MaxURLLength, if positive, is the longest request URI, path and
+	// query together, that will be routed. Longer ones get a 414.
+	MaxURLLength int
+
+	// MaxVarLength, if positive, is the longest value, in bytes, a
+	// variable may capture. A request that only matches by capturing a
+	// longer one gets a 414, like one over MaxURLLength.
+	MaxVarLength int
+
+	// MaxVars, if positive, is the most variables a request may
+	// capture; requests that would capture more get a 404. Patterns
+	// rarely have more than a few variables, so this is defense in
+	// depth against abusive paths rather than something most
+	// configurations need.
+	MaxVars int
+
+	// Debug logs every routing decision made by ServeHTTP to
+	// DebugLogger, or the standard logger if it is nil. It is meant
+	// for development; nothing is logged or formatted when it's off.
+	Debug       bool
+	DebugLogger *log.Logger
+
+	// tab holds the routes. ServeHTTP loads it once per request, so
+	// that when Reload swaps in a new table, requests in flight finish
+	// with the old one. Registering routes one at a time changes the
+	// current table in place, and isn't safe while serving.
+	tab atomic.Pointer[table]
+}
+
+// A table is a set of routes.
+type table struct {
+	trie        trie
+	pats        map[string]string
+	prioritized bool // Whether Prioritize has been called.
+	routes      int  // How many routes have been inserted.
+}
+
+// table returns the current routes.
+func (h *Handler) table() *table {
+	if tab := h.tab.Load(); tab != nil {
+		return tab
+	}
+	h.tab.CompareAndSwap(nil, &table{})
+	return h.tab.Load()
 }
 
+// A MismatchPolicy decides how a Handler responds when a request's
+// path matches a pattern that wasn't registered for its method.
+type MismatchPolicy int
+
+const (
+	// Mismatch405 always responds with 405 Method Not Allowed.
+	Mismatch405 MismatchPolicy = iota
+
+	// MismatchSafe404 responds with 404 Not Found when the method is
+	// GET or HEAD, and 405 otherwise. A 405 tells a reader that the
+	// resource exists, and caches treat the two differently, so a
+	// write-only path such as a form handler can look like it isn't
+	// there to clients that only read.
+	MismatchSafe404
+)
+
 type trie struct {
-	t       map[string]*trie
-	verbs   map[string]http.HandlerFunc
-	varName string
+	t         map[string]*trie
+	verbs     map[string]http.HandlerFunc
+	conds     map[string][]*cond // Conditional routes, tried before verbs.
+	anyMethod http.HandlerFunc   // Used when no other route has the method.
+	varName   string
+	pat       string // The cleaned pattern, set on nodes with routes.
+	raw       bool   // Whether a suffix variable captures the raw path.
+	docs      map[string]*RouteDoc
+	priority  int
+	preflight *CORSConfig
+	internal  bool      // Whether the pattern is hidden from outside requests.
+	expiry    time.Time // When the pattern stops matching, if ever.
+	// Set on variable nodes whose values are restricted.
+	constraint *constraint
 }
 
 func (h *Handler) Match(method, pat string, f http.HandlerFunc, name ...string) {
+	h.match(method, pat, route{f: f}, name)
+}
+
+// RawSuffix registers a pattern like Match, except that its suffix
+// variable captures the rest of the request path exactly as it was
+// sent: not cleaned, so "." and ".." elements and repeated slashes
+// are kept, and not decoded, so percent-encodings are left alone.
+// This is for things like object storage keys, which can contain
+// anything. It panics if the pattern has no suffix variable.
+func (h *Handler) RawSuffix(method, pat string, f http.HandlerFunc, name ...string) {
+	parts := splitPath(pat)
+	if len(parts) == 0 || parts[len(parts)-1][0] != '*' {
+		panic("route: pattern has no suffix variable")
+	}
+	h.match(method, pat, route{f: f, raw: true}, name)
+}
+
+// GetQuery registers a pattern with method "GET" that only matches
+// requests whose query has the given parameter, even an empty one. It
+// is a condition like GetHeader's, so it takes precedence over the
+// pattern's plain Get handler, which matches when the parameter is
+// absent:
+//
+//	h.GetQuery("/callback", "code", ExchangeCode)
+//	h.Get("/callback", ShowLoginError)
+func (h *Handler) GetQuery(pat, param string, f http.HandlerFunc, name ...string) {
+	h.match("GET", pat, route{f: f, cond: &cond{
+		key: "query parameter " + param,
+		ok: func(r *http.Request) bool {
+			return r.URL.Query().Has(param)
+		},
+	}}, name)
+}
+
+// GetInternal registers a pattern with method "GET" for admin and
+// other endpoints that only internal requests, as decided by the
+// Handler's InternalCheck, can reach. To anyone else the pattern
+// doesn't exist: it doesn't match, so the request goes to another
+// pattern or gets a 404, and nothing reveals that it's there, not even
+// a 405 for another method. Since visibility belongs to the pattern
+// as a whole, registering a public route with the pattern panics, and
+// so does making an existing public pattern internal.
+func (h *Handler) GetInternal(pat string, f http.HandlerFunc, name ...string) {
+	h.match("GET", pat, route{f: f, internal: true}, name)
+}
+
+// GetUntil registers a pattern with method "GET" for temporary
+// endpoints, like feature previews, that stops matching at expiry, so
+// it doesn't need to be unregistered. After that requests go to another
+// pattern or get a 404, as if it had never been registered. The
+// expiry belongs to the pattern as a whole, so registering a route
+// with the pattern that doesn't expire at the same time panics. It
+// costs a call to time.Now for every request that reaches it.
+func (h *Handler) GetUntil(pat string, expiry time.Time, f http.HandlerFunc, name ...string) {
+	h.match("GET", pat, route{f: f, expiry: expiry}, name)
+}
+
+// isInternal reports whether r may reach patterns registered with
+// GetInternal.
+func (h *Handler) isInternal(r *http.Request) bool {
+	if h.InternalCheck != nil {
+		return h.InternalCheck(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate())
+}
+
+// GetHeader registers a pattern with method "GET" that only matches
+// requests carrying the given header value. Several of these can share
+// a pattern; they are tried in the order they were registered, and
+// then the pattern's plain Get handler, if any. When none of them
+// match the pattern doesn't either, so the request goes to another
+// pattern or gets a 404.
+func (h *Handler) GetHeader(pat, headerName, headerValue string, f http.HandlerFunc, name ...string) {
+	headerName = http.CanonicalHeaderKey(headerName)
+	h.match("GET", pat, route{f: f, cond: &cond{
+		key: "header " + headerName + ": " + headerValue,
+		ok: func(r *http.Request) bool {
+			for _, v := range r.Header.Values(headerName) {
+				if v == headerValue {
+					return true
+				}
+			}
+			return false
+		},
+	}}, name)
+}
+
+// AnyMethodVar registers a pattern that matches requests with any
+// method, including ones no one has heard of. Routes registered for a
+// specific method on the same pattern take precedence over it, and the
+// pattern never responds with 405.
+func (h *Handler) AnyMethodVar(pat string, f http.HandlerFunc, name ...string) {
+	h.match("", pat, route{f: f, anyMethod: true}, name)
+}
+
+// Prioritize gives the registered pattern a priority, which decides
+// between patterns that could both match a request. The default
+// priority is 0; a higher one wins, and patterns with the same
+// priority fall back on the usual precedence of static elements over
+// variables. It only matters when patterns genuinely overlap, like
+// "/users/:userID" and "/*path" for "/users/me", but it does mean every
+// candidate has to be considered for every request. It panics if
+// nothing is registered with the pattern.
+func (h *Handler) Prioritize(pat string, priority int) {
+	ts := h.registered(pat)
+	if ts == nil {
+		panic("route: there is no route with that pattern")
+	}
+	for _, t := range ts {
+		t.priority = priority
+	}
+	h.table().prioritized = true
+}
+
+// GetInt registers a pattern with method "GET" whose variable varName,
+// e.g. ":page", only matches integers between min and max inclusive.
+// Other values don't match, so the request goes to another pattern or
+// gets a 404, or whatever HandleConstraintFail does. The constraint
+// belongs to the variable, so it also applies to patterns registered
+// later that share it. It panics if patterns already registered use
+// the variable without the same constraint.
+func (h *Handler) GetInt(pat, varName string, min, max int, f http.HandlerFunc, name ...string) {
+	found := false
+	for _, part := range splitPath(pat) {
+		if strings.TrimSuffix(part, "?") == varName && varName[0] == ':' {
+			found = true
+		}
+	}
+	if !found {
+		panic("route: " + varName + " is not a variable in the pattern")
+	}
+	h.match("GET", pat, route{f: f, constraints: map[string]*constraint{varName: {
+		key: fmt.Sprintf("int between %d and %d", min, max),
+		ok: func(s string) bool {
+			n, err := strconv.Atoi(s)
+			return err == nil && n >= min && n <= max
+		},
+	}}}, name)
+}
+
+// A route is one registration of a HandlerFunc, along with the options
+// that decide when it applies.
+type route struct {
+	f         http.HandlerFunc
+	raw       bool      // The suffix variable captures the raw path.
+	cond      *cond     // Nil unless the route only applies to some requests.
+	anyMethod bool      // The route ignores the method.
+	internal  bool      // The pattern is only for internal requests.
+	expiry    time.Time // When the pattern stops matching, if ever.
+
+	// Constraints on the values of variables, keyed by their names.
+	constraints map[string]*constraint
+}
+
+// A constraint restricts the values a variable can match to those for
+// which ok is true. The key describes it so that different ones can be
+// told apart.
+type constraint struct {
+	key string
+	ok  func(string) bool
+}
+
+// A cond restricts a route to the requests for which ok is true. The
+// key describes the condition so that duplicates can be detected.
+type cond struct {
+	key string
+	ok  func(*http.Request) bool
+	f   http.HandlerFunc
+}
+
+func (h *Handler) match(method, pat string, rt route, name []string) {
+	if err := h.add(method, pat, rt, name); err != nil {
+		panic(err.Error())
+	}
+}
+
+// add registers a route, or returns an error and leaves h alone if
+// it can't.
+func (h *Handler) add(method, pat string, rt route, name []string) error {
 	if pat == "" {
-		panic(`route: "" is not a valid pattern"`)
+		return errors.New(`route: "" is not a valid pattern"`)
 	}
-	if f == nil {
-		panic("route: nil is not a valid HandlerFunc")
+	if rt.f == nil {
+		return errors.New("route: nil is not a valid HandlerFunc")
 	}
 	if len(name) > 1 {
-		panic("route: a pattern can have only one name")
+		return errors.New("route: a pattern can have only one name")
 	}
+	pat = h.normalize(pat)
 	if len(name) == 1 {
-		if _, ok := h.pats[name[0]]; ok {
-			panic("route: there is a registered pattern by the same name")
+		if err := h.checkName(name[0], pat); err != nil {
+			return err
 		}
 	}
-	p := path.Clean(pat)
-	parts := []string{}
-	if p != "/" {
-		parts = strings.Split(p[1:], "/")
-	}
-	t := &h.trie
+	parts := splitPath(pat)
 	for i, part := range parts {
-		// Is part a :var?
-		if part[0] == ':' {
-			if t.varName != "" {
-				if t.varName != part {
-					panic("route: pattern conflicts with one already registered")
-				}
-				t = t.t[part]
-				continue
-			}
-			t.varName = part
-			if t.t == nil {
-				t.t = map[string]*trie{}
-			}
-			t.t[part] = &trie{}
-			t = t.t[part]
-			continue
+		if strings.HasSuffix(part, "?") && (i < len(parts)-1 || part[0] != ':') {
+			return errors.New("route: only the last element can be optional, and only if it is a variable")
 		}
-		// Is part a *var?
-		if part[0] == '*' {
-			if i < len(parts)-1 {
-				panic("route: suffix variables cannot contain '/'")
-			}
-			if t.varName != "" {
-				if t.varName != part {
-					panic("route: pattern conflicts with one already registered")
-				}
-				t = t.t[part]
-				break
+		if part[0] == '*' && i < len(parts)-1 {
+			return errors.New("route: suffix variables cannot contain '/'")
+		}
+	}
+	shown := method
+	if rt.anyMethod {
+		shown = "*"
+	}
+	tab := h.table()
+	n := len(parts)
+	optional := n > 0 && strings.HasSuffix(parts[n-1], "?")
+	if max := h.MaxRoutes; max > 0 && (tab.routes >= max || optional && tab.routes+1 >= max) {
+		return fmt.Errorf("route: more than %d routes: %s %s", max, shown, pat)
+	}
+	if optional {
+		// Register the pattern both with and without the variable.
+		full := append([]string{}, parts...)
+		full[n-1] = strings.TrimSuffix(full[n-1], "?")
+		if err := tab.trie.check(method, parts[:n-1], rt); err != nil {
+			return fmt.Errorf("%v: %s %s", err, shown, pat)
+		}
+		if err := tab.trie.check(method, full, rt); err != nil {
+			return fmt.Errorf("%v: %s %s", err, shown, pat)
+		}
+		tab.trie.insert(method, parts[:n-1], rt)
+		tab.trie.insert(method, full, rt)
+		tab.routes += 2
+	} else {
+		if err := tab.trie.check(method, parts, rt); err != nil {
+			return fmt.Errorf("%v: %s %s", err, shown, pat)
+		}
+		tab.trie.insert(method, parts, rt)
+		tab.routes++
+	}
+	h.name(name, pat)
+	return nil
+}
+
+var (
+	errConflict = errors.New("route: pattern conflicts with one already registered")
+	errInternal = errors.New("route: internal and public routes can't share a pattern")
+	errExpiry   = errors.New("route: routes that share a pattern must expire together")
+)
+
+// check returns errConflict, or a more specific error, if the route
+// can't be inserted at parts because of the routes already below t.
+func (t *trie) check(method string, parts []string, rt route) error {
+	for _, part := range parts {
+		if part[0] == ':' || part[0] == '*' {
+			if t.varName != "" && t.varName != part {
+				return errConflict
 			}
-			t.varName = part
-			if t.t == nil {
-				t.t = map[string]*trie{}
+		}
+		t = t.t[part]
+		if t == nil {
+			// Nothing is registered below here.
+			return nil
+		}
+		if c := rt.constraints[part]; c != nil && (t.constraint == nil && t.count() > 0 || t.constraint != nil && t.constraint.key != c.key) {
+			// Constraining the variable would change routes that are
+			// already registered.
+			return errConflict
+		}
+	}
+	if len(parts) > 0 && parts[len(parts)-1][0] == '*' && t.raw != rt.raw {
+		return errConflict
+	}
+	if !t.empty() && t.internal != rt.internal {
+		return errInternal
+	}
+	if !t.empty() && !t.expiry.Equal(rt.expiry) {
+		return errExpiry
+	}
+	switch {
+	case rt.anyMethod:
+		if t.anyMethod != nil {
+			return errConflict
+		}
+	case rt.cond != nil:
+		for _, c := range t.conds[method] {
+			if c.key == rt.cond.key {
+				return errConflict
 			}
-			t.t[part] = &trie{}
-			t = t.t[part]
-			break
 		}
-		// Part is not a var.
+	default:
+		if _, ok := t.verbs[method]; ok {
+			return errConflict
+		}
+	}
+	return nil
+}
+
+// insert adds the route at parts below t. It must pass check first.
+func (t *trie) insert(method string, parts []string, rt route) {
+	for _, part := range parts {
 		if _, ok := t.t[part]; !ok {
 			if t.t == nil {
 				t.t = map[string]*trie{}
 			}
 			t.t[part] = &trie{}
+			if part[0] == ':' || part[0] == '*' {
+				t.varName = part
+			}
+			if part[0] == '*' {
+				t.t[part].raw = rt.raw
+			}
 		}
 		t = t.t[part]
+		if c := rt.constraints[part]; c != nil {
+			t.constraint = c
+		}
 	}
-	if _, ok := t.verbs[method]; ok {
-		panic("route: pattern conflicts with one already registered")
+	t.internal = rt.internal
+	t.expiry = rt.expiry
+	switch {
+	case rt.anyMethod:
+		t.anyMethod = rt.f
+	case rt.cond != nil:
+		if t.conds == nil {
+			t.conds = map[string][]*cond{}
+		}
+		c := *rt.cond
+		c.f = rt.f
+		t.conds[method] = append(t.conds[method], &c)
+	default:
+		if t.verbs == nil {
+			t.verbs = map[string]http.HandlerFunc{}
+		}
+		t.verbs[method] = rt.f
 	}
-	if t.verbs == nil {
-		t.verbs = map[string]http.HandlerFunc{}
+	t.pat = "/" + strings.Join(parts, "/")
+}
+
+// checkName returns an error if name is taken by a pattern other than
+// pat. Giving the same pattern the same name again, say when
+// registering it for another method, is fine.
+func (h *Handler) checkName(name, pat string) error {
+	if p, ok := h.table().pats[name]; ok && cleanPath(p) != cleanPath(pat) {
+		return fmt.Errorf("route: the name %s is taken by %s, so it can't be given to %s", name, p, pat)
 	}
-	t.verbs[method] = f
+	return nil
+}
+
+// name gives pat the name, if there is one. A name given again keeps
+// the pattern as it was first spelled.
+func (h *Handler) name(name []string, pat string) {
 	if len(name) == 1 {
-		if h.pats == nil {
-			h.pats = map[string]string{}
+		tab := h.table()
+		if tab.pats == nil {
+			tab.pats = map[string]string{}
+		}
+		if _, ok := tab.pats[name[0]]; !ok {
+			tab.pats[name[0]] = pat
 		}
-		h.pats[name[0]] = pat
+	}
+}
+
+// Methods registers the same HandlerFunc for each of the given
+// methods. The name, if any, is given to the pattern once. Either all
+// of the methods are registered or, if any of them conflict, none are
+// and it panics.
+func (h *Handler) Methods(methods []string, pat string, f http.HandlerFunc, name ...string) {
+	if len(name) > 1 {
+		panic("route: a pattern can have only one name")
+	}
+	routes := make([]Route, len(methods))
+	for i, m := range methods {
+		routes[i] = Route{Method: m, Pattern: pat, Handler: f}
+	}
+	if len(name) == 1 && len(routes) > 0 {
+		routes[0].Name = name[0]
+	}
+	if err := h.RegisterAll(routes); err != nil {
+		panic(err.Error())
+	}
+}
+
+// GetMulti registers the HandlerFunc with method "GET" under each of
+// the patterns, for resources with more than one URL. The name, if
+// any, goes to the first pattern, which is taken to be the canonical
+// one. Either all of the patterns are registered or, if any of them
+// conflict, none are and it panics.
+func (h *Handler) GetMulti(pats []string, f http.HandlerFunc, name ...string) {
+	if len(name) > 1 {
+		panic("route: a pattern can have only one name")
+	}
+	routes := make([]Route, len(pats))
+	for i, pat := range pats {
+		routes[i] = Route{Method: "GET", Pattern: pat, Handler: f}
+	}
+	if len(name) == 1 && len(routes) > 0 {
+		routes[0].Name = name[0]
+	}
+	if err := h.RegisterAll(routes); err != nil {
+		panic(err.Error())
 	}
 }
 
@@ -293,19 +890,19 @@ func (h *Handler) Opt(pat string, f http.HandlerFunc, name ...string) {
 }
 
 func (h *Handler) URL(name string, args ...string) string {
-	pat, ok := h.pats[name]
+	pat, ok := h.table().pats[name]
 	if !ok {
 		panic("route: there is no pattern by that name")
 	}
-	pat = path.Clean(pat)
-	parts := []string{}
-	if pat != "/" {
-		parts = strings.Split(pat[1:], "/")
-	}
+	parts := splitPath(pat)
 	argi := 0
 	for i, part := range parts {
 		switch part[0] {
 		case ':', '*':
+			if argi == len(args) && strings.HasSuffix(part, "?") {
+				parts[i] = ""
+				continue
+			}
 			if argi == len(args) {
 				panic("route: not enough arguments to fill in the pattern")
 			}
@@ -319,6 +916,46 @@ func (h *Handler) URL(name string, args ...string) string {
 	return "/" + path.Join(parts...)
 }
 
+// RemovePrefix removes every route whose pattern starts with the
+// given prefix, along with their names, and returns the number of
+// routes removed. The prefix is compared element by element, so
+// "/foo" removes "/foo" and "/foo/bar" but not "/foobar". Variables in
+// the prefix must be spelled the way they were registered.
+func (h *Handler) RemovePrefix(prefix string) int {
+	tab := h.table()
+	parts := splitPath(h.normalize(prefix))
+	ts := []*trie{&tab.trie}
+	for _, part := range parts {
+		t, ok := ts[len(ts)-1].t[part]
+		if !ok {
+			return 0
+		}
+		ts = append(ts, t)
+	}
+	n := ts[len(ts)-1].count()
+	if len(parts) == 0 {
+		tab.trie = trie{}
+	} else {
+		// Detach the subtree, then prune ancestors left without routes.
+		for i := len(parts) - 1; i >= 0; i-- {
+			if i < len(parts)-1 && (!ts[i+1].empty() || len(ts[i+1].t) > 0) {
+				break
+			}
+			delete(ts[i].t, parts[i])
+			if ts[i].varName == parts[i] {
+				ts[i].varName = ""
+			}
+		}
+	}
+	for name, pat := range tab.pats {
+		if hasPrefix(splitPath(strings.TrimSuffix(pat, "?")), parts) {
+			delete(tab.pats, name)
+		}
+	}
+	tab.routes -= n
+	return n
+}
+
 // ServeHTTP dispatches to the HandlerFunc whose pattern matches the
 // request.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -329,49 +966,110 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		}()
 	}
-	p := path.Clean(r.URL.Path)
-	parts := []string{}
-	if p != "/" {
-		parts = strings.Split(p[1:], "/")
+	if h.Debug {
+		h.debugf("%s %s", r.Method, r.URL.Path)
 	}
-	t := &h.trie
-	for i, part := range parts {
-		// Try to match exactly first.
-		if part[0] != ':' && part[0] != '*' {
-			if t2, ok := t.t[part]; ok {
-				t = t2
-				continue
-			}
+	if h.MaxURLLength > 0 {
+		uri := r.RequestURI
+		if uri == "" {
+			uri = r.URL.RequestURI()
 		}
-		// Try to use a variable instead.
-		if t.varName == "" {
-			h.handle404(w, r)
+		if len(uri) > h.MaxURLLength {
+			if h.Debug {
+				h.debugf("  URI is %d bytes long: 414", len(uri))
+			}
+			h.handle414(w, r)
 			return
 		}
-		if t.varName[0] == '*' {
-			r.URL.RawQuery = appendQuery(r.URL.RawQuery, t.varName, strings.Join(parts[i:], "/"))
-			t = t.t[t.varName]
-			break
+	}
+	r.URL.RawQuery = removeVars(r.URL.RawQuery)
+	mi := &matchInfo{path: cleanPath(h.normalize(r.URL.Path)), escaped: r.URL.EscapedPath()}
+	if h.Domain != "" {
+		mi.subdomain = subdomain(r.Host, h.Domain)
+	}
+	r = withInfo(r, mi)
+	tab := h.table()
+	m := &matcher{h: h, r: r, parts: splitPath(mi.path), prioritized: tab.prioritized || h.MostSpecific}
+	if h.Debug {
+		m.trace = h.debugf
+	}
+	t := m.match(&tab.trie)
+	if t == nil && m.tooLong {
+		if h.Debug {
+			h.debugf("  no pattern matches without a variable that's too long: 414")
 		}
-		r.URL.RawQuery = appendQuery(r.URL.RawQuery, t.varName, part)
-		t = t.t[t.varName]
+		h.handle414(w, r)
+		return
 	}
-	if len(t.verbs) == 0 {
+	if t == nil && m.rejected != nil && h.HandleConstraintFail != nil {
+		if h.Debug {
+			h.debugf("  no pattern matches without %s=%q: constraint failure", m.rejected.name, m.rejected.value)
+		}
+		h.HandleConstraintFail(w, r, m.rejected.name, m.rejected.value)
+		return
+	}
+	if t == nil {
+		if h.Debug {
+			h.debugf("  no pattern matches: 404")
+		}
 		h.handle404(w, r)
 		return
 	}
+	for _, c := range m.caps {
+		k, v := h.encodeVar(c.name, c.value)
+		r.URL.RawQuery = appendQuery(r.URL.RawQuery, k, v)
+	}
+	mi.parts, mi.caps, mi.raw, mi.pat, mi.tab = m.parts, m.caps, t.raw, t.pat, tab
+	if cfg := t.preflight; isPreflight(r) && (cfg != nil || h.CORS != nil) {
+		if cfg == nil {
+			cfg = h.CORS
+		}
+		if h.Debug {
+			h.debugf("  matched %s: CORS preflight", t.pat)
+		}
+		cfg.preflight(w, r, t)
+		return
+	}
+	if c := m.cond; c != nil {
+		if h.Debug {
+			h.debugf("  matched %s %s with %s", r.Method, t.pat, c.key)
+		}
+		c.f(w, r)
+		return
+	}
 	f, ok := t.verbs[r.Method]
+	if !ok && t.anyMethod != nil {
+		f, ok = t.anyMethod, true
+	}
 	if !ok {
-		verbs := []string{}
-		for k := range t.verbs {
-			verbs = append(verbs, k)
+		if h.Debug {
+			h.debugf("  matched %s but not %s", t.pat, r.Method)
 		}
-		h.handle405(w, r, verbs)
+		h.handle405(w, r, t.methods())
 		return
 	}
+	if h.Debug {
+		h.debugf("  matched %s %s", r.Method, t.pat)
+	}
 	f(w, r)
 }
 
+func (h *Handler) handle414(w http.ResponseWriter, r *http.Request) {
+	if h.Handle414 != nil {
+		h.Handle414(w, r)
+		return
+	}
+	http.Error(w, "414 request URI too long", 414)
+}
+
+func (h *Handler) debugf(format string, args ...interface{}) {
+	if h.DebugLogger != nil {
+		h.DebugLogger.Printf("route: "+format, args...)
+		return
+	}
+	log.Printf("route: "+format, args...)
+}
+
 func (h *Handler) handle404(w http.ResponseWriter, r *http.Request) {
 	if h.Handle404 != nil {
 		h.Handle404(w, r)
@@ -381,6 +1079,10 @@ func (h *Handler) handle404(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handle405(w http.ResponseWriter, r *http.Request, verbs []string) {
+	if h.Mismatch == MismatchSafe404 && (r.Method == "GET" || r.Method == "HEAD") {
+		h.handle404(w, r)
+		return
+	}
 	w.Header().Set("Allow", strings.Join(verbs, ", "))
 	if h.Handle405 != nil {
 		h.Handle405(w, r)
@@ -389,8 +1091,119 @@ func (h *Handler) handle405(w http.ResponseWriter, r *http.Request, verbs []stri
 	http.Error(w, "405 method not allowed", 405)
 }
 
+// rawSuffix returns what is left of the escaped path p once n
+// elements of the cleaned path have been consumed. The suffix starts
+// where the cleaned path first has those n elements for good, that is,
+// where no later ".." takes one of them away, so "/a/../files/k" is
+// "k" after one element, like "/files/k", while ".." elements within
+// the suffix are kept. Elements are classified after unescaping, the
+// way the matched path was cleaned.
+func rawSuffix(p string, n int) string {
+	segs := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	// depth[j] is the number of elements left after cleaning segs[:j].
+	depth := make([]int, len(segs)+1)
+	for j, seg := range segs {
+		d := depth[j]
+		if u, err := url.PathUnescape(seg); err == nil {
+			seg = u
+		}
+		switch seg {
+		case "", ".":
+		case "..":
+			if d > 0 {
+				d--
+			}
+		default:
+			d++
+		}
+		depth[j+1] = d
+	}
+	start, low := -1, depth[len(segs)]
+	for j := len(segs); j >= 0; j-- {
+		if depth[j] < low {
+			low = depth[j]
+		}
+		if depth[j] == n && low >= n {
+			start = j
+		}
+	}
+	if start == -1 {
+		return ""
+	}
+	return strings.Join(segs[start:], "/")
+}
+
+// empty reports whether t has no routes of its own.
+func (t *trie) empty() bool {
+	return len(t.verbs) == 0 && len(t.conds) == 0 && t.anyMethod == nil
+}
+
+// methods returns the sorted methods registered at t.
+func (t *trie) methods() []string {
+	verbs := []string{}
+	for k := range t.verbs {
+		verbs = append(verbs, k)
+	}
+	for k := range t.conds {
+		if _, ok := t.verbs[k]; !ok {
+			verbs = append(verbs, k)
+		}
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+// count returns the number of routes registered at or below t.
+func (t *trie) count() int {
+	n := len(t.verbs)
+	for _, cs := range t.conds {
+		n += len(cs)
+	}
+	if t.anyMethod != nil {
+		n++
+	}
+	for _, t2 := range t.t {
+		n += t2.count()
+	}
+	return n
+}
+
+// cleanPath is path.Clean for paths that may lack a leading slash.
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// splitPath cleans p and splits it into its elements. The root path
+// has no elements.
+func splitPath(p string) []string {
+	p = cleanPath(p)
+	if p == "/" {
+		return []string{}
+	}
+	return strings.Split(p[1:], "/")
+}
+
+func hasPrefix(parts, prefix []string) bool {
+	if len(prefix) > len(parts) {
+		return false
+	}
+	for i := range prefix {
+		if parts[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) encodeVar(name, value string) (key, encodedValue string) {
+	if h.VarEncoder != nil {
+		return h.VarEncoder(name, value)
+	}
+	return url.QueryEscape(name), url.QueryEscape(value)
+}
+
 func appendQuery(query, key, value string) string {
-	s := url.QueryEscape(key) + "=" + url.QueryEscape(value)
+	s := key + "=" + value
 	if query == "" {
 		return s
 	}
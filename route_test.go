@@ -0,0 +1,730 @@
+package route
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ok returns a HandlerFunc that responds with s.
+func ok(s string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(s)) }
+}
+
+// do serves a request with the given method and target on h.
+func do(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// panics reports whether f panics.
+func panics(f func()) (panicked bool) {
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}
+
+func TestMaxRoutes(t *testing.T) {
+	h := &Handler{MaxRoutes: 3}
+	h.Get("/a", ok("a"))
+	h.Get("/b/:x?", ok("b")) // Counts twice.
+	if !panics(func() { h.Get("/c", ok("c")) }) {
+		t.Error("registering a 4th route didn't panic")
+	}
+	if n := len(h.Routes()); n != 3 {
+		t.Errorf("got %d routes, want 3", n)
+	}
+	if err := h.RegisterAll([]Route{{Method: "GET", Pattern: "/d", Handler: ok("d")}}); err == nil {
+		t.Error("RegisterAll over the limit didn't fail")
+	}
+	err := h.Reload(func(h2 *Handler) error {
+		for _, p := range []string{"/1", "/2", "/3", "/4"} {
+			h2.Get(p, ok(p))
+		}
+		return nil
+	})
+	if err == nil {
+		t.Error("Reload over the limit didn't fail")
+	}
+
+	h = &Handler{MaxRoutes: 2}
+	h.Get("/a", ok("a"))
+	if !panics(func() { h.Get("/b/:x?", ok("b")) }) {
+		t.Error("an optional variable over the limit didn't panic")
+	}
+	h.Get("/b", ok("b"))
+	if n := h.RemovePrefix("/a"); n != 1 {
+		t.Errorf("removed %d routes, want 1", n)
+	}
+	if panics(func() { h.Get("/c", ok("c")) }) {
+		t.Error("removed routes still count toward the limit")
+	}
+	if !panics(func() { h.Get("/d", ok("d")) }) {
+		t.Error("registering a 3rd route didn't panic")
+	}
+}
+
+func TestRawSuffix(t *testing.T) {
+	h := &Handler{}
+	var got string
+	h.RawSuffix("GET", "/files/*key", func(w http.ResponseWriter, r *http.Request) { got = r.FormValue("*key") })
+	for target, want := range map[string]string{
+		"/files/a/b":           "a/b",
+		"/files/a%2Fb/c%20d":   "a%2Fb/c%20d",
+		"/files/a//b/./c":      "a//b/./c",
+		"/files/x/../y":        "x/../y",
+		"/a/../files/k":        "k",
+		"/files/../files/k":    "k",
+		"/a/%2e%2e/files/k":    "k",
+		"//files/k":            "k",
+		"/files/./k":           "./k",
+		"/x/y/../../files/a/b": "a/b",
+	} {
+		got = ""
+		r := httptest.NewRequest("GET", "/", nil)
+		r.URL.RawPath, r.URL.Path = target, mustUnescape(t, target)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got != want {
+			t.Errorf("%s: captured %q, want %q", target, got, want)
+		}
+	}
+	if !panics(func() { h.RawSuffix("GET", "/nosuffix/:x", ok("")) }) {
+		t.Error("a pattern without a suffix variable didn't panic")
+	}
+}
+
+func mustUnescape(t *testing.T, p string) string {
+	u, err := url.PathUnescape(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestGetHeader(t *testing.T) {
+	h := &Handler{}
+	h.GetHeader("/a/b", "X-Version", "2", ok("v2"))
+	h.GetHeader("/a/b", "x-version", "3", ok("v3"))
+	h.Get("/a/:x", ok("var"))
+	h.GetHeader("/c", "X-Version", "2", ok("c2"))
+	h.Get("/c", ok("c"))
+	h.GetHeader("/only", "X-Version", "2", ok("only"))
+	for _, c := range []struct {
+		target, version string
+		code            int
+		body            string
+	}{
+		{"/a/b", "2", 200, "v2"},
+		{"/a/b", "3", 200, "v3"},
+		{"/a/b", "", 200, "var"},
+		{"/a/b", "4", 200, "var"},
+		{"/c", "2", 200, "c2"},
+		{"/c", "", 200, "c"},
+		{"/only", "2", 200, "only"},
+		{"/only", "", 404, ""},
+	} {
+		r := httptest.NewRequest("GET", c.target, nil)
+		if c.version != "" {
+			r.Header.Set("X-Version", c.version)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.code || c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%s with version %q: got %d %q", c.target, c.version, w.Code, w.Body.String())
+		}
+	}
+	if w := do(h, "POST", "/only"); w.Code != 405 || w.Header().Get("Allow") != "GET" {
+		t.Errorf("POST got %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	if !panics(func() { h.GetHeader("/c", "X-Version", "2", ok("again")) }) {
+		t.Error("registering the same condition twice didn't panic")
+	}
+}
+
+func TestWebDAVMethods(t *testing.T) {
+	h := &Handler{}
+	h.Methods(WebDAVMethods[:3], "/dav/*path", ok("dav"), "dav")
+	h.Get("/dav/*path", ok("get"))
+	for _, m := range []string{"PROPFIND", "PROPPATCH", "MKCOL", "GET"} {
+		if w := do(h, m, "/dav/a/b"); w.Code != 200 {
+			t.Errorf("%s got %d", m, w.Code)
+		}
+	}
+	w := do(h, "MOVE", "/dav/a/b")
+	if w.Code != 405 {
+		t.Errorf("MOVE got %d, want 405", w.Code)
+	}
+	if got, want := w.Header().Get("Allow"), "GET, MKCOL, PROPFIND, PROPPATCH"; got != want {
+		t.Errorf("Allow is %q, want %q", got, want)
+	}
+	if got := h.URL("dav", "x"); got != "/dav/x" {
+		t.Errorf("URL is %q", got)
+	}
+
+	// It's all or nothing.
+	h.Match("MOVE", "/d", ok("move"))
+	if !panics(func() { h.Methods(WebDAVMethods, "/d", ok("dav")) }) {
+		t.Error("a conflicting method didn't panic")
+	}
+	if w := do(h, "COPY", "/d"); w.Code != 405 || w.Header().Get("Allow") != "MOVE" {
+		t.Errorf("COPY got %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestRemovePrefix(t *testing.T) {
+	h := &Handler{}
+	h.Get("/plugins/a", ok("a"), "a")
+	h.Get("/plugins/a/:id", ok("a id"))
+	h.Pst("/plugins/a/:id", ok("post a id"))
+	h.Get("/plugins/b", ok("b"), "b")
+	h.Get("/plugins/abc", ok("abc"))
+	h.Get("/users/:id/posts", ok("posts"))
+	h.Get("/users/:id/likes", ok("likes"))
+	if n := h.RemovePrefix("/plugins/a"); n != 3 {
+		t.Errorf("removed %d routes, want 3", n)
+	}
+	for target, code := range map[string]int{
+		"/plugins/a":     404,
+		"/plugins/a/1":   404,
+		"/plugins/b":     200,
+		"/plugins/abc":   200,
+		"/users/1/posts": 200,
+	} {
+		if w := do(h, "GET", target); w.Code != code {
+			t.Errorf("%s: got %d, want %d", target, w.Code, code)
+		}
+	}
+	if !panics(func() { h.URL("a") }) {
+		t.Error("the removed route's name is still there")
+	}
+	if h.URL("b") != "/plugins/b" {
+		t.Error("the remaining route's name is gone")
+	}
+
+	// Variables have to be spelled as registered.
+	if n := h.RemovePrefix("/users/:userID"); n != 0 {
+		t.Errorf("removed %d routes with a misspelled variable", n)
+	}
+	if n := h.RemovePrefix("/users/:id/posts"); n != 1 {
+		t.Errorf("removed %d routes, want 1", n)
+	}
+	if w := do(h, "GET", "/users/1/likes"); w.Code != 200 {
+		t.Errorf("sibling of a removed route got %d", w.Code)
+	}
+	if h.RemovePrefix("/users/:id/likes"); h.RemovePrefix("/users") != 0 {
+		t.Error("empty nodes were left behind")
+	}
+	// With the variable pruned, a different one can be registered.
+	h.Get("/users/:name", ok("name"))
+
+	// An optional variable's name goes with its longer form.
+	h.Get("/items/:id?", ok("items"), "items")
+	if n := h.RemovePrefix("/items/:id"); n != 1 {
+		t.Errorf("removed %d routes, want 1", n)
+	}
+	if !panics(func() { h.URL("items", "5") }) {
+		t.Error("the name of a removed optional variable is still there")
+	}
+	if w := do(h, "GET", "/items"); w.Code != 200 {
+		t.Errorf("the form without the variable got %d", w.Code)
+	}
+
+	if n := h.RemovePrefix("/"); n != 4 || len(h.Routes()) != 0 {
+		t.Errorf("removing everything removed %d, left %v", n, h.Routes())
+	}
+}
+
+func TestDebug(t *testing.T) {
+	var buf bytes.Buffer
+	h := &Handler{DebugLogger: log.New(&buf, "", 0)}
+	h.Get("/users/:id/posts", ok("posts"))
+	h.Get("/users/me", ok("me"))
+	do(h, "GET", "/users/me/posts")
+	if buf.Len() != 0 {
+		t.Errorf("logged with Debug off: %q", buf.String())
+	}
+
+	h.Debug = true
+	do(h, "GET", "/users/me/posts")
+	want := `route: GET /users/me/posts
+route:   "users" matched exactly
+route:   "me" matched exactly
+route:   "posts" matched nothing at depth 3
+route:   backing up from "me"
+route:   "me" captured as :id
+route:   "posts" matched exactly
+route:   matched GET /users/:id/posts
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+
+	for target, line := range map[string]string{
+		"/nope":    "route:   no pattern matches: 404\n",
+		"/users/1": "route:   no pattern ends here\n",
+	} {
+		buf.Reset()
+		do(h, "GET", target)
+		if got := buf.String(); !strings.Contains(got, line) {
+			t.Errorf("%s: got\n%s", target, got)
+		}
+	}
+	buf.Reset()
+	do(h, "POST", "/users/me")
+	if got := buf.String(); !strings.HasSuffix(got, "route:   matched /users/me but not POST\n") {
+		t.Errorf("POST: got\n%s", got)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	for _, c := range []struct {
+		policy       MismatchPolicy
+		method, path string
+		code         int
+	}{
+		{Mismatch405, "GET", "/form", 405},
+		{Mismatch405, "HEAD", "/form", 405},
+		{Mismatch405, "POST", "/page", 405},
+		{MismatchSafe404, "GET", "/form", 404},
+		{MismatchSafe404, "HEAD", "/form", 404},
+		{MismatchSafe404, "POST", "/page", 405},
+		{MismatchSafe404, "DELETE", "/form", 405},
+	} {
+		h := &Handler{Mismatch: c.policy}
+		h.Pst("/form", ok("form"))
+		h.Get("/page", ok("page"))
+		w := do(h, c.method, c.path)
+		if w.Code != c.code {
+			t.Errorf("policy %d, %s %s: got %d, want %d", c.policy, c.method, c.path, w.Code, c.code)
+		}
+		if allow := w.Header().Get("Allow"); (c.code == 405) != (allow != "") {
+			t.Errorf("policy %d, %s %s: Allow is %q", c.policy, c.method, c.path, allow)
+		}
+	}
+}
+
+func TestCleanRequest(t *testing.T) {
+	var logged []string
+	logging := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			clean := CleanRequest(r)
+			logged = append(logged, clean.URL.String())
+			if _, ok := clean.Form[":id"]; ok || clean.Form.Get("q") != "x" {
+				t.Errorf("clean form is %v", clean.Form)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := &Handler{}
+	h.Get("/users/:id/*rest", Chain(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.FormValue(":id") + " " + r.FormValue("*rest")))
+	}, logging))
+	for target, want := range map[string]string{
+		"/users/1/a/b?q=x":       "/users/1/a/b?q=x",
+		"/users/1/a?q=x&%3Aid=2": "/users/1/a?q=x",
+	} {
+		logged = nil
+		w := do(h, "GET", target)
+		if len(logged) != 1 || logged[0] != want {
+			t.Errorf("%s: middleware saw %q, want %q", target, logged, want)
+		}
+		if got := w.Body.String(); got != "1 a/b" && got != "1 a" {
+			t.Errorf("%s: handler saw %q", target, got)
+		}
+	}
+}
+
+// FuzzStripVars checks the invariant StripVars documents: whatever
+// the client's query and the captured variables, stripping them gives
+// back the query that was routed, and FormValue sees the variables.
+func FuzzStripVars(f *testing.F) {
+	for _, seed := range [][5]string{
+		{"", "id", "1", "path", "a/b"},
+		{"q=x", "id", "a&b=c", "path", ""},
+		{"a=1&&b=2&", "a=b", "%", "&", "="},
+		{"%3Aid=spoof&*path=spoof&x=1", "id", "real", "path", "real"},
+		{"%zz&:=&*", "&amp;", "+ ;?", "%3A", "%2"},
+		{"&", "", "", "", ""},
+	} {
+		f.Add(seed[0], seed[1], seed[2], seed[3], seed[4])
+	}
+	f.Fuzz(func(t *testing.T, q, n1, v1, n2, v2 string) {
+		h := &Handler{}
+		routed := removeVars(q)
+		if StripVars(routed) != routed {
+			t.Fatalf("StripVars(%q) isn't the identity on a query without variables", routed)
+		}
+		got := routed
+		caps := [][2]string{{":" + n1, v1}, {"*" + n2, v2}}
+		for _, c := range caps {
+			k, v := h.encodeVar(c[0], c[1])
+			got = appendQuery(got, k, v)
+		}
+		if s := StripVars(got); s != routed {
+			t.Fatalf("StripVars(%q) = %q, want %q", got, s, routed)
+		}
+		vals, _ := url.ParseQuery(got)
+		for _, c := range caps {
+			if vs := vals[c[0]]; len(vs) != 1 || vs[0] != c[1] {
+				t.Errorf("%s is %q in %q, want %q", c[0], vs, got, c[1])
+			}
+		}
+	})
+}
+
+func TestAnyMethodVar(t *testing.T) {
+	h := &Handler{}
+	h.AnyMethodVar("/proxy/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("any " + MatchedMethod(r)))
+	})
+	h.Get("/proxy/*path", ok("get"))
+	for method, want := range map[string]string{
+		"GET":        "get",
+		"POST":       "any POST",
+		"DELETE":     "any DELETE",
+		"PROPFIND":   "any PROPFIND",
+		"FROBNICATE": "any FROBNICATE",
+	} {
+		if w := do(h, method, "/proxy/a/b"); w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: got %d %q, want %q", method, w.Code, w.Body.String(), want)
+		}
+	}
+	if w := do(h, "POST", "/other"); w.Code != 404 {
+		t.Errorf("another path got %d", w.Code)
+	}
+	var msg interface{}
+	func() {
+		defer func() { msg = recover() }()
+		h.AnyMethodVar("/proxy/*path", ok("again"))
+	}()
+	if want := "route: pattern conflicts with one already registered: * /proxy/*path"; msg != want {
+		t.Errorf("registering it twice panicked with %v, want %q", msg, want)
+	}
+}
+
+func TestOptionalVar(t *testing.T) {
+	h := &Handler{}
+	h.Get("/items/:id?", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("items " + r.FormValue(":id")))
+	}, "items")
+	for target, want := range map[string]string{
+		"/items":     "items ",
+		"/items/":    "items ",
+		"/items/123": "items 123",
+	} {
+		if w := do(h, "GET", target); w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: got %d %q, want %q", target, w.Code, w.Body.String(), want)
+		}
+	}
+	if w := do(h, "GET", "/items/1/2"); w.Code != 404 {
+		t.Errorf("an extra element got %d", w.Code)
+	}
+	if u := h.URL("items"); u != "/items" {
+		t.Errorf("URL without the variable is %q", u)
+	}
+	if u := h.URL("items", "5"); u != "/items/5" {
+		t.Errorf("URL with the variable is %q", u)
+	}
+	for _, pat := range []string{"/a/:x?/b", "/b/*rest?/c", "/c/d?"} {
+		if !panics(func() { h.Get(pat, ok("")) }) {
+			t.Errorf("%s didn't panic", pat)
+		}
+	}
+	if !panics(func() { h.Get("/items", ok("")) }) {
+		t.Error("the pattern without the variable didn't conflict")
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	h := &Handler{MaxURLLength: 10}
+	h.Get("/*path", ok("ok"))
+	for target, code := range map[string]int{
+		"/123456789":   200,
+		"/1234567890":  414,
+		"/a?b=123456":  414,
+		"/a?b=12345":   200,
+		"/%C3%BC12345": 414,
+	} {
+		if w := do(h, "GET", target); w.Code != code {
+			t.Errorf("%s: got %d, want %d", target, w.Code, code)
+		}
+	}
+	var hooked string
+	h.Handle414 = func(w http.ResponseWriter, r *http.Request) {
+		hooked = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(414)
+		w.Write([]byte(`{"error":414}`))
+	}
+	w := do(h, "GET", "/1234567890")
+	if w.Code != 414 || w.Body.String() != `{"error":414}` || hooked != "/1234567890" {
+		t.Errorf("the hook got %q and wrote %d %q", hooked, w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", "/short"); w.Code != 200 || hooked != "/1234567890" {
+		t.Errorf("the hook ran for a short URL: %d", w.Code)
+	}
+}
+
+func TestGetInt(t *testing.T) {
+	h := &Handler{}
+	h.GetInt("/pages/:page", ":page", 1, 1000, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page " + r.FormValue(":page")))
+	})
+	h.Get("/pages/last", ok("last"))
+	h.GetInt("/years/:y?", ":y", -100, 3000, ok("year"))
+	for _, c := range []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/pages/1", 200, "page 1"},
+		{"/pages/1000", 200, "page 1000"},
+		{"/pages/0", 404, ""},
+		{"/pages/1001", 404, ""},
+		{"/pages/-5", 404, ""},
+		{"/pages/abc", 404, ""},
+		{"/pages/1.5", 404, ""},
+		{"/pages/99999999999999999999", 404, ""},
+		{"/pages/last", 200, "last"},
+		{"/years", 200, "year"},
+		{"/years/-1", 200, "year"},
+		{"/years/3001", 404, ""},
+	} {
+		w := do(h, "GET", c.target)
+		if w.Code != c.code || c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%s: got %d %q, want %d %q", c.target, w.Code, w.Body.String(), c.code, c.body)
+		}
+	}
+	if !panics(func() { h.GetInt("/x/:a", ":b", 0, 1, ok("")) }) {
+		t.Error("a variable not in the pattern didn't panic")
+	}
+	if !panics(func() { h.GetInt("/pages/:page/x", ":page", 0, 5, ok("")) }) {
+		t.Error("other bounds on the same variable didn't conflict")
+	}
+
+	// A constraint can't be added to routes already sharing the variable.
+	h.Get("/items/:id/edit", ok("edit"))
+	if !panics(func() { h.GetInt("/items/:id", ":id", 1, 10, ok("item")) }) {
+		t.Error("constraining a shared variable didn't conflict")
+	}
+	if w := do(h, "GET", "/items/abc/edit"); w.Body.String() != "edit" {
+		t.Errorf("the unconstrained route got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestNameReuse(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:id", ok("get"), "user")
+	if panics(func() { h.Put("/users/:id/", ok("put"), "user") }) {
+		t.Error("reusing a name for the same pattern panicked")
+	}
+	var msg interface{}
+	func() {
+		defer func() { msg = recover() }()
+		h.Get("/people/:id", ok("people"), "user")
+	}()
+	want := "route: the name user is taken by /users/:id, so it can't be given to /people/:id"
+	if msg != want {
+		t.Errorf("reusing a name for another pattern panicked with %v, want %q", msg, want)
+	}
+	if w := do(h, "GET", "/people/1"); w.Code != 404 {
+		t.Errorf("the rejected pattern was registered: %d", w.Code)
+	}
+	if h.URL("user", "1") != "/users/1" {
+		t.Error("the name moved")
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	h := &Handler{}
+	h.GetMulti([]string{"/users/:id", "/u/:id", "/people/:id"}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + r.FormValue(":id")))
+	}, "user")
+	for _, target := range []string{"/users/5", "/u/5", "/people/5"} {
+		if w := do(h, "GET", target); w.Body.String() != "user 5" {
+			t.Errorf("%s: got %d %q", target, w.Code, w.Body.String())
+		}
+	}
+	if u := h.URL("user", "5"); u != "/users/5" {
+		t.Errorf("the name went to %q", u)
+	}
+	if !panics(func() { h.GetMulti([]string{"/a", "/u/:id", "/b"}, ok("")) }) {
+		t.Error("a conflict didn't panic")
+	}
+	for _, target := range []string{"/a", "/b"} {
+		if w := do(h, "GET", target); w.Code != 404 {
+			t.Errorf("%s was registered despite the conflict", target)
+		}
+	}
+}
+
+func TestGetInternal(t *testing.T) {
+	h := &Handler{}
+	h.GetInternal("/admin/stats", ok("stats"))
+	h.Get("/*path", ok("app"))
+	serve := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/admin/stats", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for remote, want := range map[string]string{
+		"127.0.0.1:5000":   "stats",
+		"[::1]:5000":       "stats",
+		"10.1.2.3:5000":    "stats",
+		"192.168.0.7:5000": "stats",
+		"[fd00::1]:5000":   "stats",
+		"8.8.8.8:5000":     "app",
+		"[2001:db8::1]:80": "app",
+		"bogus":            "app",
+		"":                 "app",
+	} {
+		if got := serve(remote).Body.String(); got != want {
+			t.Errorf("from %q: got %q, want %q", remote, got, want)
+		}
+	}
+
+	h2 := &Handler{InternalCheck: func(r *http.Request) bool { return r.Header.Get("X-Internal") == "yes" }}
+	h2.GetInternal("/admin", ok("admin"))
+	r := httptest.NewRequest("GET", "/admin", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	w := httptest.NewRecorder()
+	if h2.ServeHTTP(w, r); w.Code != 404 {
+		t.Errorf("InternalCheck was ignored: %d", w.Code)
+	}
+	r.Header.Set("X-Internal", "yes")
+	w = httptest.NewRecorder()
+	if h2.ServeHTTP(w, r); w.Body.String() != "admin" {
+		t.Errorf("InternalCheck passed but got %d", w.Code)
+	}
+	// Other methods don't reveal the pattern with a 405.
+	r = httptest.NewRequest("POST", "/admin", nil)
+	w = httptest.NewRecorder()
+	if h2.ServeHTTP(w, r); w.Code != 404 {
+		t.Errorf("POST from outside got %d", w.Code)
+	}
+
+	// Internal and public routes can't share a pattern.
+	if !panics(func() { h2.Pst("/admin", ok("post")) }) {
+		t.Error("a public route on an internal pattern didn't panic")
+	}
+	h2.Pst("/public", ok("post"))
+	if !panics(func() { h2.GetInternal("/public", ok("get")) }) {
+		t.Error("an internal route on a public pattern didn't panic")
+	}
+	r = httptest.NewRequest("POST", "/public", nil)
+	w = httptest.NewRecorder()
+	if h2.ServeHTTP(w, r); w.Body.String() != "post" {
+		t.Errorf("the public route got %d", w.Code)
+	}
+}
+
+func TestVarEncoder(t *testing.T) {
+	h := &Handler{VarEncoder: func(name, value string) (string, string) {
+		return name, url.QueryEscape(value)
+	}}
+	var query, stripped string
+	var vars map[string]string
+	var form string
+	h.Get("/users/:id/*path", func(w http.ResponseWriter, r *http.Request) {
+		query, stripped, vars = r.URL.RawQuery, StripVars(r.URL.RawQuery), Vars(r)
+		form = r.FormValue(":id") + " " + r.FormValue("*path")
+	})
+	do(h, "GET", "/users/a%20b%26c/x/y?q=1&%3Aid=spoof")
+	if want := "q=1&:id=a+b%26c&*path=x%2Fy"; query != want {
+		t.Errorf("query is %q, want %q", query, want)
+	}
+	if stripped != "q=1" {
+		t.Errorf("stripped query is %q", stripped)
+	}
+	if vars[":id"] != "a b&c" || vars["*path"] != "x/y" {
+		t.Errorf("vars are %v", vars)
+	}
+	if form != "a b&c x/y" {
+		t.Errorf("FormValue got %q", form)
+	}
+}
+
+func TestGetQuery(t *testing.T) {
+	h := &Handler{}
+	h.GetQuery("/callback", "code", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("exchange " + r.FormValue("code")))
+	})
+	h.Get("/callback", ok("login error"))
+	h.GetQuery("/preview/page", "token", ok("preview"))
+	h.Get("/preview/:x", ok("var"))
+	for _, c := range []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/callback?code=abc&state=xyz", 200, "exchange abc"},
+		{"/callback?code=", 200, "exchange "},
+		{"/callback?code", 200, "exchange "},
+		{"/callback", 200, "login error"},
+		{"/callback?state=xyz", 200, "login error"},
+		{"/callback?%3Acode=spoof", 200, "login error"},
+		{"/preview/page?token=t", 200, "preview"},
+		{"/preview/page", 200, "var"},
+		{"/preview/other?token=t", 200, "var"},
+	} {
+		w := do(h, "GET", c.target)
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Errorf("%s: got %d %q, want %d %q", c.target, w.Code, w.Body.String(), c.code, c.body)
+		}
+	}
+	h.GetQuery("/only", "code", ok("only"))
+	if w := do(h, "GET", "/only"); w.Code != 404 {
+		t.Errorf("a conditional route matched without its parameter: %d", w.Code)
+	}
+}
+
+func TestGetUntil(t *testing.T) {
+	h := &Handler{}
+	h.GetUntil("/preview", time.Now().Add(time.Hour), ok("preview"))
+	h.GetUntil("/old", time.Now().Add(-time.Hour), ok("old"))
+	for _, c := range []struct {
+		method, target string
+		code           int
+	}{
+		{"GET", "/preview", 200},
+		{"GET", "/old", 404},
+		// Other methods don't reveal the pattern with a 405.
+		{"POST", "/old", 404},
+	} {
+		if w := do(h, c.method, c.target); w.Code != c.code {
+			t.Errorf("%s %s: got %d, want %d", c.method, c.target, w.Code, c.code)
+		}
+	}
+
+	// Routes that don't expire together can't share a pattern.
+	if !panics(func() { h.Pst("/old", ok("post")) }) {
+		t.Error("a route without an expiry on an expiring pattern didn't panic")
+	}
+	h.Pst("/forever", ok("post"))
+	if !panics(func() { h.GetUntil("/forever", time.Now().Add(time.Hour), ok("get")) }) {
+		t.Error("an expiring route on a lasting pattern didn't panic")
+	}
+	if w := do(h, "POST", "/forever"); w.Body.String() != "post" {
+		t.Errorf("the lasting route got %d", w.Code)
+	}
+
+	// Once it expires, requests go to other patterns.
+	h.GetUntil("/promo/:code", time.Now().Add(-time.Hour), ok("promo"))
+	h.Get("/*rest", ok("fallback"))
+	if w := do(h, "GET", "/promo/x"); w.Body.String() != "fallback" {
+		t.Errorf("an expired pattern got %d %q", w.Code, w.Body.String())
+	}
+}
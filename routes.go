@@ -0,0 +1,444 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method    string    `json:"method"`              // Empty for routes registered with AnyMethodVar.
+	Pattern   string    `json:"pattern"`             // Cleaned, e.g. "/users/:userID".
+	Name      string    `json:"name,omitempty"`      // The pattern's name, if it has one.
+	Condition string    `json:"condition,omitempty"` // What the request must satisfy, e.g. for GetHeader.
+	Doc       *RouteDoc `json:"doc,omitempty"`       // Set by Describe.
+}
+
+// RouteDoc documents a route.
+type RouteDoc struct {
+	Summary string `json:"summary,omitempty"`
+
+	// Params describes the pattern's variables, keyed by their names
+	// as they appear in the pattern, e.g. ":userID".
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Describe documents the route registered on the DefaultHandler with
+// the given method and pattern.
+func Describe(method, pat string, doc RouteDoc) {
+	DefaultHandler.Describe(method, pat, doc)
+}
+
+// Routes returns the routes registered on the DefaultHandler.
+func Routes() []RouteInfo {
+	return DefaultHandler.Routes()
+}
+
+// Describe attaches documentation to the route registered with the
+// given method and pattern, which then shows up in Routes. It panics
+// if there is no such route, or if doc describes a variable that isn't
+// in the pattern.
+func (h *Handler) Describe(method, pat string, doc RouteDoc) {
+	ts := h.registered(pat)
+	found := len(ts) > 0
+	for _, t := range ts {
+		found = found && t.has(method)
+	}
+	if !found {
+		panic("route: there is no route with that method and pattern")
+	}
+	parts := splitPath(h.normalize(pat))
+	for k := range doc.Params {
+		found = false
+		for _, part := range parts {
+			if strings.TrimSuffix(part, "?") == k && (part[0] == ':' || part[0] == '*') {
+				found = true
+			}
+		}
+		if !found {
+			panic("route: " + k + " is not a variable in the pattern")
+		}
+	}
+	for _, t := range ts {
+		if t.docs == nil {
+			t.docs = map[string]*RouteDoc{}
+		}
+		t.docs[method] = &doc
+	}
+}
+
+// Routes returns every registered route, sorted by pattern and then by
+// method.
+func (h *Handler) Routes() []RouteInfo {
+	tab := h.table()
+	names := tab.names()
+	routes := []RouteInfo{}
+	tab.trie.walk(func(t *trie) {
+		for _, m := range t.methods() {
+			for _, c := range t.conds[m] {
+				routes = append(routes, RouteInfo{Method: m, Pattern: t.pat, Name: names[t.pat], Condition: c.key, Doc: t.docs[m]})
+			}
+			if _, ok := t.verbs[m]; ok {
+				routes = append(routes, RouteInfo{Method: m, Pattern: t.pat, Name: names[t.pat], Doc: t.docs[m]})
+			}
+		}
+		if t.anyMethod != nil {
+			routes = append(routes, RouteInfo{Pattern: t.pat, Name: names[t.pat], Doc: t.docs[""]})
+		}
+	})
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// AliasPrefix registers every route on the DefaultHandler again under
+// the given prefix.
+func AliasPrefix(prefix string) {
+	DefaultHandler.AliasPrefix(prefix)
+}
+
+// AliasPrefix registers every route again with prefix in front of its
+// pattern, so "/users/:userID" aliased under "/app" also matches
+// "/app/users/:userID". Named patterns get a name for their alias too:
+// "post" becomes "/app:post". It takes a snapshot, so routes added
+// afterwards aren't aliased. If any alias conflicts with a registered
+// route, none are registered and it panics.
+func (h *Handler) AliasPrefix(prefix string) {
+	tab := h.table()
+	prefix = cleanPath(h.normalize(prefix))
+	if prefix == "/" {
+		prefix = ""
+	}
+	type alias struct {
+		method, pat string
+		rt          route
+		doc         *RouteDoc
+		priority    int
+		preflight   *CORSConfig
+	}
+	aliases := []alias{}
+	tab.trie.walk(func(t *trie) {
+		pat := prefix + t.pat
+		base := route{raw: t.raw, internal: t.internal, expiry: t.expiry, constraints: tab.trie.constraints(splitPath(t.pat))}
+		for m, f := range t.verbs {
+			rt := base
+			rt.f = f
+			aliases = append(aliases, alias{m, pat, rt, t.docs[m], t.priority, t.preflight})
+		}
+		for m, cs := range t.conds {
+			for _, c := range cs {
+				rt := base
+				rt.f, rt.cond = c.f, &cond{key: c.key, ok: c.ok}
+				aliases = append(aliases, alias{m, pat, rt, t.docs[m], t.priority, t.preflight})
+			}
+		}
+		if t.anyMethod != nil {
+			rt := base
+			rt.f, rt.anyMethod = t.anyMethod, true
+			aliases = append(aliases, alias{"", pat, rt, t.docs[""], t.priority, t.preflight})
+		}
+	})
+	names := map[string]string{}
+	for name, pat := range tab.pats {
+		names[prefix+":"+name] = prefix + cleanPath(pat)
+	}
+	h2 := h.draft()
+	for _, a := range aliases {
+		h2.match(a.method, a.pat, a.rt, nil)
+		t := h2.table().trie.find(splitPath(a.pat))
+		t.priority, t.preflight = a.priority, a.preflight
+		if a.doc != nil {
+			if t.docs == nil {
+				t.docs = map[string]*RouteDoc{}
+			}
+			t.docs[a.method] = a.doc
+		}
+	}
+	for name, pat := range names {
+		if err := h2.checkName(name, pat); err != nil {
+			panic(err.Error())
+		}
+		h2.name([]string{name}, pat)
+	}
+	h.tab.Store(h2.table())
+}
+
+// A Route is a route to be registered by RegisterAll.
+type Route struct {
+	Method  string
+	Pattern string
+	Handler http.HandlerFunc
+	Name    string // Optional.
+}
+
+// RegisterAll registers the routes on the DefaultHandler, all or
+// none.
+func RegisterAll(routes []Route) error {
+	return DefaultHandler.RegisterAll(routes)
+}
+
+// RegisterAll registers the routes if none of them conflict, with the
+// registered routes or with each other. Otherwise it registers none
+// of them, and returns an error listing every problem, so they can be
+// fixed in one go.
+func (h *Handler) RegisterAll(routes []Route) error {
+	h2 := h.draft()
+	errs := []error{}
+	for _, r := range routes {
+		name := []string{}
+		if r.Name != "" {
+			name = append(name, r.Name)
+		}
+		if err := h2.add(r.Method, r.Pattern, route{f: r.Handler}, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	h.tab.Store(h2.table())
+	return nil
+}
+
+// draft returns a Handler with a copy of h's routes, for registering
+// routes that are swapped into h only if all of them succeed.
+func (h *Handler) draft() *Handler {
+	tab := h.table()
+	h2 := &Handler{NormalizeUnicode: h.NormalizeUnicode, MaxRoutes: h.MaxRoutes}
+	h2.tab.Store(&table{trie: *tab.trie.clone(), pats: map[string]string{}, prioritized: tab.prioritized, routes: tab.routes})
+	for name, pat := range tab.pats {
+		h2.table().pats[name] = pat
+	}
+	return h2
+}
+
+// Reload replaces the routes on the DefaultHandler with those built by
+// build.
+func Reload(build func(*Handler) error) error {
+	return DefaultHandler.Reload(build)
+}
+
+// Reload calls build with an empty Handler, which normalizes patterns
+// and limits their number like h does, and, if it succeeds, replaces
+// all of h's routes with the ones build registered, in one step, so
+// that requests being served see either the old routes or the new ones
+// and never a mix. If build returns an error or panics, as registering
+// a conflicting route does, h is left untouched and the error is
+// returned. Only the routes and their names are taken from the new
+// Handler; h keeps its own hooks and settings.
+func (h *Handler) Reload(build func(*Handler) error) (err error) {
+	h2 := &Handler{NormalizeUnicode: h.NormalizeUnicode, MaxRoutes: h.MaxRoutes}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	if err := build(h2); err != nil {
+		return err
+	}
+	h.tab.Store(h2.table())
+	return nil
+}
+
+// clone returns a copy of t that can be changed without affecting t.
+func (t *trie) clone() *trie {
+	t2 := *t
+	if t.t != nil {
+		t2.t = map[string]*trie{}
+		for k, c := range t.t {
+			t2.t[k] = c.clone()
+		}
+	}
+	if t.verbs != nil {
+		t2.verbs = map[string]http.HandlerFunc{}
+		for k, f := range t.verbs {
+			t2.verbs[k] = f
+		}
+	}
+	if t.conds != nil {
+		t2.conds = map[string][]*cond{}
+		for k, cs := range t.conds {
+			t2.conds[k] = append([]*cond{}, cs...)
+		}
+	}
+	if t.docs != nil {
+		t2.docs = map[string]*RouteDoc{}
+		for k, d := range t.docs {
+			t2.docs[k] = d
+		}
+	}
+	return &t2
+}
+
+// RoutesForMethod returns the routes registered on the DefaultHandler
+// for the given method.
+func RoutesForMethod(method string) []RouteInfo {
+	return DefaultHandler.RoutesForMethod(method)
+}
+
+// RoutesForMethod returns the routes registered for the given method,
+// sorted by pattern, e.g. every "POST" route for a list of endpoints
+// that need CSRF protection. Routes registered with AnyMethodVar are
+// only included for the empty method.
+func (h *Handler) RoutesForMethod(method string) []RouteInfo {
+	routes := []RouteInfo{}
+	for _, ri := range h.Routes() {
+		if ri.Method == method {
+			routes = append(routes, ri)
+		}
+	}
+	return routes
+}
+
+// MarshalRoutes encodes the routes on the DefaultHandler.
+func MarshalRoutes() ([]byte, error) {
+	return DefaultHandler.MarshalRoutes()
+}
+
+// MarshalRoutes encodes what Routes returns as JSON, so that tools can
+// check or document the routes without importing the code that
+// registers them. The HandlerFuncs obviously aren't included. Use
+// UnmarshalRoutes to decode it.
+func (h *Handler) MarshalRoutes() ([]byte, error) {
+	return json.Marshal(h.Routes())
+}
+
+// UnmarshalRoutes decodes routes encoded by MarshalRoutes.
+func UnmarshalRoutes(data []byte) ([]RouteInfo, error) {
+	routes := []RouteInfo{}
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// LongestPrefix returns the longest prefix of path that the patterns
+// registered on the DefaultHandler share, following static segments
+// only.
+func LongestPrefix(path string) string {
+	return DefaultHandler.LongestPrefix(path)
+}
+
+// LongestPrefix returns the longest prefix of path, in whole segments,
+// that is also a static prefix of some registered pattern, even if no
+// pattern matches the whole path. It's meant for helpful 404 pages,
+// e.g. "no such page under /docs", and for analytics. Variables in the
+// patterns are not followed, so the result is "/" if only a variable
+// could match the first segment.
+func (h *Handler) LongestPrefix(path string) string {
+	t := &h.table().trie
+	prefix := []string{}
+	for _, part := range splitPath(h.normalize(path)) {
+		if part[0] == ':' || part[0] == '*' {
+			break
+		}
+		t2, ok := t.t[part]
+		if !ok {
+			break
+		}
+		t = t2
+		prefix = append(prefix, part)
+	}
+	return "/" + strings.Join(prefix, "/")
+}
+
+// names returns the names of the registered patterns, keyed by the
+// patterns of the nodes they were inserted at, the first name in order
+// for a pattern with several. A pattern with an optional variable is
+// at two nodes, so its name is under both.
+func (tab *table) names() map[string]string {
+	names := map[string]string{}
+	add := func(pat, name string) {
+		if n, ok := names[pat]; !ok || name < n {
+			names[pat] = name
+		}
+	}
+	for name, pat := range tab.pats {
+		p := cleanPath(pat)
+		if full := strings.TrimSuffix(p, "?"); full != p {
+			add(full, name)
+			add(path.Dir(full), name)
+			continue
+		}
+		add(p, name)
+	}
+	return names
+}
+
+// find returns the node reached by following parts literally, or nil.
+func (t *trie) find(parts []string) *trie {
+	for _, part := range parts {
+		t2, ok := t.t[part]
+		if !ok {
+			return nil
+		}
+		t = t2
+	}
+	return t
+}
+
+// registered returns the nodes the pattern was registered at: one, or
+// two for a pattern with an optional variable, spelled either way.
+// It returns nil if a node is missing or has no routes.
+func (h *Handler) registered(pat string) []*trie {
+	parts := splitPath(h.normalize(pat))
+	forms := [][]string{parts}
+	if n := len(parts); n > 0 && strings.HasSuffix(parts[n-1], "?") {
+		full := append([]string{}, parts...)
+		full[n-1] = strings.TrimSuffix(full[n-1], "?")
+		forms = [][]string{parts[:n-1], full}
+	}
+	ts := []*trie{}
+	for _, f := range forms {
+		t := h.table().trie.find(f)
+		if t == nil || t.empty() {
+			return nil
+		}
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// has reports whether t has a route for method, where the empty method
+// stands for routes registered with AnyMethodVar.
+func (t *trie) has(method string) bool {
+	if method == "" {
+		return t.anyMethod != nil
+	}
+	_, ok := t.verbs[method]
+	return ok || len(t.conds[method]) > 0
+}
+
+// constraints returns the constraints on the variables along parts
+// below t, keyed by their names.
+func (t *trie) constraints(parts []string) map[string]*constraint {
+	cs := map[string]*constraint{}
+	for _, part := range parts {
+		if t = t.t[part]; t == nil {
+			break
+		}
+		if t.constraint != nil {
+			cs[part] = t.constraint
+		}
+	}
+	return cs
+}
+
+// walk calls f for every node at or below t that has routes.
+func (t *trie) walk(f func(*trie)) {
+	if !t.empty() {
+		f(t)
+	}
+	for _, t2 := range t.t {
+		t2.walk(f)
+	}
+}
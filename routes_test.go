@@ -0,0 +1,373 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAliasPrefix(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:userID", ok("user"), "user")
+	h.GetInt("/p/:n", ":n", 1, 10, ok("page"))
+	h.GetHeader("/beta", "X-Beta", "1", ok("beta"))
+	h.Describe("GET", "/users/:userID", RouteDoc{Summary: "A user."})
+	h.AliasPrefix("/app")
+	for _, c := range []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/users/5", 200, "user"},
+		{"/app/users/5", 200, "user"},
+		{"/p/3", 200, "page"},
+		{"/app/p/3", 200, "page"},
+		{"/p/abc", 404, ""},
+		{"/app/p/abc", 404, ""},
+		{"/app/p/11", 404, ""},
+		{"/app/beta", 404, ""},
+	} {
+		w := do(h, "GET", c.target)
+		if w.Code != c.code || c.code == 200 && w.Body.String() != c.body {
+			t.Errorf("%s: got %d %q", c.target, w.Code, w.Body.String())
+		}
+	}
+	if u := h.URL("/app:user", "5"); u != "/app/users/5" {
+		t.Errorf("alias URL is %q", u)
+	}
+	for _, ri := range h.Routes() {
+		if ri.Pattern == "/app/users/:userID" && (ri.Doc == nil || ri.Doc.Summary != "A user.") {
+			t.Errorf("alias lost its doc: %+v", ri)
+		}
+	}
+	if !panics(func() { h.AliasPrefix("/app") }) {
+		t.Error("aliasing twice didn't panic")
+	}
+
+	// It's all or nothing.
+	h3 := &Handler{}
+	h3.Get("/a", ok("a"))
+	h3.Get("/b", ok("b"))
+	h3.Get("/x/b", ok("taken"))
+	if !panics(func() { h3.AliasPrefix("/x") }) {
+		t.Error("a conflicting alias didn't panic")
+	}
+	if w := do(h3, "GET", "/x/a"); w.Code != 404 {
+		t.Errorf("an alias was registered despite the conflict: %d", w.Code)
+	}
+
+	// The prefix is normalized like patterns are.
+	h4 := &Handler{NormalizeUnicode: true}
+	h4.Get("/menu", ok("menu"), "menu")
+	h4.Prioritize("/menu", 1)
+	h4.AliasPrefix("/cafe\u0301")
+	if w := do(h4, "GET", "/caf\u00e9/menu"); w.Body.String() != "menu" {
+		t.Errorf("the normalized alias got %d %q", w.Code, w.Body.String())
+	}
+	if u := h4.URL("/caf\u00e9:menu"); u != "/caf\u00e9/menu" {
+		t.Errorf("the alias's URL is %q", u)
+	}
+
+	// It's a snapshot; later routes aren't aliased.
+	h.Get("/later", ok("later"))
+	if w := do(h, "GET", "/app/later"); w.Code != 404 {
+		t.Errorf("a route registered later was aliased: %d", w.Code)
+	}
+	// The root alias is the prefix itself.
+	h2 := &Handler{}
+	h2.Get("/", ok("index"))
+	h2.AliasPrefix("/app")
+	if w := do(h2, "GET", "/app"); w.Body.String() != "index" {
+		t.Errorf("/app got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	h := &Handler{}
+	h.Get("/docs/intro", ok("intro"))
+	h.Get("/docs/api/:name", ok("api"))
+	h.Get("/users/:id/posts", ok("posts"))
+	for path, want := range map[string]string{
+		"/docs/missing":       "/docs",
+		"/docs/api/x/y":       "/docs/api",
+		"/docs//intro/extra/": "/docs/intro",
+		"/docs/intro":         "/docs/intro",
+		"/nothing":            "/",
+		"/users/5/posts":      "/users",
+		"/":                   "/",
+		"":                    "/",
+	} {
+		if got := h.LongestPrefix(path); got != want {
+			t.Errorf("LongestPrefix(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRoutesOptionalName(t *testing.T) {
+	h := &Handler{}
+	h.Get("/items/:itemID?", ok("items"), "items")
+	h.Get("/users/:userID", ok("user"), "user")
+	h.Pst("/users/:userID", ok("user"))
+	h.Get("/plain", ok("plain"))
+	got := map[string]string{}
+	for _, ri := range h.Routes() {
+		got[ri.Method+" "+ri.Pattern] = ri.Name
+	}
+	want := map[string]string{
+		"GET /items":          "items",
+		"GET /items/:itemID":  "items",
+		"GET /users/:userID":  "user",
+		"POST /users/:userID": "user",
+		"GET /plain":          "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	b, err := h.OpenAPI(OpenAPIInfo{Title: "t", Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), `"operationId":"items.get"`); n != 1 {
+		t.Errorf("operationId items appears %d times in %s", n, b)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:userID/files/*path", ok("file"))
+	h.Pst("/users/:userID/files/*path", ok("upload"))
+	doc := RouteDoc{Summary: "Gets a file.", Params: map[string]string{":userID": "The owner.", "*path": "Where it is."}}
+	h.Describe("GET", "/users/:userID/files/*path", doc)
+	for _, ri := range h.Routes() {
+		switch ri.Method {
+		case "GET":
+			if ri.Doc == nil || !reflect.DeepEqual(*ri.Doc, doc) {
+				t.Errorf("GET doc is %+v", ri.Doc)
+			}
+		case "POST":
+			if ri.Doc != nil {
+				t.Errorf("POST got a doc: %+v", ri.Doc)
+			}
+		}
+	}
+	h.Get("/items/:id?", ok("items"))
+	h.Describe("GET", "/items/:id?", RouteDoc{Summary: "Items.", Params: map[string]string{":id": "One item."}})
+	for _, ri := range h.Routes() {
+		if strings.HasPrefix(ri.Pattern, "/items") && (ri.Doc == nil || ri.Doc.Summary != "Items.") {
+			t.Errorf("%s has doc %+v", ri.Pattern, ri.Doc)
+		}
+	}
+	for _, c := range []struct {
+		method, pat string
+		doc         RouteDoc
+	}{
+		{"PUT", "/users/:userID/files/*path", RouteDoc{}},
+		{"GET", "/users/:id/files/*path", RouteDoc{}},
+		{"GET", "/users/:userID/files/*path", RouteDoc{Params: map[string]string{":id": "nope"}}},
+		{"GET", "/users/:userID/files/*path", RouteDoc{Params: map[string]string{"files": "static"}}},
+	} {
+		if !panics(func() { h.Describe(c.method, c.pat, c.doc) }) {
+			t.Errorf("Describe(%s, %s, %+v) didn't panic", c.method, c.pat, c.doc)
+		}
+	}
+}
+
+func TestRegisterAll(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:id", ok("user"), "user")
+	err := h.RegisterAll([]Route{
+		{Method: "GET", Pattern: "/ok", Handler: ok("ok")},
+		{Method: "GET", Pattern: "/users/:userID", Handler: ok("taken")},
+		{Method: "GET", Pattern: "/a", Handler: ok("a")},
+		{Method: "GET", Pattern: "/a/", Handler: ok("a again")},
+		{Method: "GET", Pattern: "/b", Handler: ok("b"), Name: "user"},
+		{Method: "GET", Pattern: "/c", Handler: nil},
+		{Method: "GET", Pattern: "/d/*rest/e", Handler: ok("d")},
+	})
+	if err == nil {
+		t.Fatal("conflicting routes weren't an error")
+	}
+	want := []string{
+		"route: pattern conflicts with one already registered: GET /users/:userID",
+		"route: pattern conflicts with one already registered: GET /a/",
+		"route: the name user is taken by /users/:id, so it can't be given to /b",
+		"route: nil is not a valid HandlerFunc",
+		"route: suffix variables cannot contain '/'",
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, p := range []string{"/ok", "/a"} {
+		if w := do(h, "GET", p); w.Code != 404 {
+			t.Errorf("%s was registered by a failed RegisterAll: %d", p, w.Code)
+		}
+	}
+
+	if err := h.RegisterAll([]Route{
+		{Method: "GET", Pattern: "/ok", Handler: ok("ok"), Name: "ok"},
+		{Method: "POST", Pattern: "/users/:id", Handler: ok("post")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(h, "POST", "/users/5"); w.Body.String() != "post" {
+		t.Errorf("POST got %q", w.Body.String())
+	}
+	if h.URL("ok") != "/ok" || h.URL("user", "5") != "/users/5" {
+		t.Error("names weren't kept")
+	}
+}
+
+func TestReload(t *testing.T) {
+	h := &Handler{Handle404: ok("custom 404")}
+	h.Get("/old", ok("old"), "old")
+	for _, build := range []func(*Handler) error{
+		func(h2 *Handler) error {
+			h2.Get("/new", ok("new"))
+			return errors.New("config is bad")
+		},
+		func(h2 *Handler) error {
+			h2.Get("/new", ok("new"))
+			h2.Get("/new/", ok("conflict"))
+			return nil
+		},
+	} {
+		if err := h.Reload(build); err == nil {
+			t.Error("a failing build wasn't an error")
+		}
+		if w := do(h, "GET", "/old"); w.Body.String() != "old" {
+			t.Errorf("after a failing build /old got %q", w.Body.String())
+		}
+		if w := do(h, "GET", "/new"); w.Body.String() != "custom 404" {
+			t.Errorf("after a failing build /new got %q", w.Body.String())
+		}
+	}
+	if err := h.Reload(func(h2 *Handler) error {
+		h2.Get("/new", ok("new"), "new")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(h, "GET", "/new"); w.Body.String() != "new" {
+		t.Errorf("after reloading /new got %q", w.Body.String())
+	}
+	if w := do(h, "GET", "/old"); w.Body.String() != "custom 404" {
+		t.Errorf("after reloading /old got %q", w.Body.String())
+	}
+	if h.URL("new") != "/new" || !panics(func() { h.URL("old") }) {
+		t.Error("names weren't replaced")
+	}
+}
+
+func TestRoutesForMethod(t *testing.T) {
+	h := &Handler{}
+	h.Get("/b", ok("b"))
+	h.Pst("/b", ok("b"), "b")
+	h.Pst("/a/:id", ok("a"))
+	h.Del("/a/:id", ok("a"))
+	h.Get("/c", ok("c"))
+	h.AnyMethodVar("/any", ok("any"))
+	got := []string{}
+	for _, ri := range h.RoutesForMethod("POST") {
+		got = append(got, ri.Method+" "+ri.Pattern+" "+ri.Name)
+	}
+	if want := []string{"POST /a/:id ", "POST /b b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("POST routes are %q, want %q", got, want)
+	}
+	if rs := h.RoutesForMethod("PATCH"); len(rs) != 0 {
+		t.Errorf("PATCH routes are %v", rs)
+	}
+	if rs := h.RoutesForMethod(""); len(rs) != 1 || rs[0].Pattern != "/any" {
+		t.Errorf("any-method routes are %v", rs)
+	}
+}
+
+func TestReloadWhileServing(t *testing.T) {
+	h := &Handler{}
+	build := func(gen int) func(*Handler) error {
+		return func(h2 *Handler) error {
+			f := ok(strconv.Itoa(gen))
+			h2.Get("/static", f)
+			h2.Get("/users/:id", f)
+			h2.Get("/files/*path", f)
+			// Routes that come and go between generations.
+			h2.Get(fmt.Sprintf("/gen/%d", gen), f)
+			return nil
+		}
+	}
+	if err := h.Reload(build(0)); err != nil {
+		t.Fatal(err)
+	}
+	const gens = 200
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, target := range []string{"/static", "/users/5", "/files/a/b"} {
+					w := do(h, "GET", target)
+					gen, err := strconv.Atoi(w.Body.String())
+					if w.Code != 200 || err != nil || gen < last {
+						t.Errorf("%s: got %d %q after generation %d", target, w.Code, w.Body.String(), last)
+						return
+					}
+					last = gen
+				}
+			}
+		}()
+	}
+	for gen := 1; gen <= gens; gen++ {
+		if gen%2 == 0 {
+			if err := h.Reload(build(gen)); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		// RegisterAll swaps in a new table too.
+		if err := h.RegisterAll([]Route{{Method: "POST", Pattern: fmt.Sprintf("/extra/%d", gen), Handler: ok("")}}); err != nil {
+			t.Error(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if w := do(h, "GET", "/static"); w.Body.String() != strconv.Itoa(gens) {
+		t.Errorf("after the last reload got %q", w.Body.String())
+	}
+	if w := do(h, "GET", fmt.Sprintf("/gen/%d", gens-2)); w.Code != 404 {
+		t.Errorf("an old generation's route still matches: %d", w.Code)
+	}
+}
+
+func TestMarshalRoutes(t *testing.T) {
+	h := &Handler{}
+	h.Get("/users/:id", ok("user"), "user")
+	h.GetHeader("/users/:id", "X-Version", "2", ok("v2"))
+	h.Pst("/users", ok("create"))
+	h.AnyMethodVar("/proxy/*path", ok("proxy"))
+	h.Describe("GET", "/users/:id", RouteDoc{Summary: "A user.", Params: map[string]string{":id": "Its ID."}})
+	b, err := h.MarshalRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnmarshalRoutes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := h.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip gave %+v, want %+v", got, want)
+	}
+	if _, err := UnmarshalRoutes([]byte("{")); err == nil {
+		t.Error("bad JSON wasn't an error")
+	}
+}
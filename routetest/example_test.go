@@ -0,0 +1,71 @@
+package routetest_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rynlbrwn/route"
+	"github.com/rynlbrwn/route/routetest"
+)
+
+// requireAuth only lets requests with the right bearer token through.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "401 unauthorized", 401)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getAdmin(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "welcome, admin")
+}
+
+func Example() {
+	h := &route.Handler{}
+	h.Get("/admin", route.Chain(getAdmin, requireAuth))
+
+	w := routetest.Serve(h, routetest.Get("/admin").Request())
+	fmt.Println(w.Code)
+
+	w = routetest.Serve(h, routetest.Get("/admin").Header("Authorization", "Bearer secret").Request())
+	fmt.Println(w.Code, w.Body.String())
+	// Output:
+	// 401
+	// 200 welcome, admin
+}
+
+func ExampleRequest_JSON() {
+	h := &route.Handler{}
+	h.Pst("/users", func(w http.ResponseWriter, r *http.Request) {
+		var u struct{ Name string }
+		if err := route.DecodeJSON(r, &u); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		w.WriteHeader(201)
+		fmt.Fprintf(w, "created %s", u.Name)
+	})
+
+	req := routetest.Post("/users").JSON(map[string]string{"name": "ada"}).Request()
+	w := routetest.Serve(h, req)
+	fmt.Println(w.Code, w.Body.String())
+	// Output:
+	// 201 created ada
+}
+
+func ExampleRequest_Body() {
+	h := &route.Handler{}
+	h.Put("/notes/:noteID", func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 64)
+		n, _ := r.Body.Read(buf)
+		fmt.Fprintf(w, "%s: %s (%s)", r.FormValue(":noteID"), buf[:n], r.Header.Get("Content-Type"))
+	})
+
+	req := routetest.Put("/notes/7").Body("hello").Header("Content-Type", "text/plain").Request()
+	fmt.Println(routetest.Serve(h, req).Body.String())
+	// Output:
+	// 7: hello (text/plain)
+}
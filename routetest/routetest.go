@@ -0,0 +1,104 @@
+// Package routetest has helpers for testing handlers, routers and
+// their middleware together, without the boilerplate of building
+// requests and recorders by hand. It works with any http.Handler.
+//
+//	h := &route.Handler{}
+//	h.Get("/admin", RequireAuth(GetAdmin))
+//
+//	w := routetest.Serve(h, routetest.Get("/admin").Request())
+//	if w.Code != 401 {
+//	  t.Fatalf("got %d without credentials", w.Code)
+//	}
+//	w = routetest.Serve(h, routetest.Get("/admin").Header("Authorization", "Bearer secret").Request())
+//	if w.Code != 200 {
+//	  t.Fatalf("got %d with credentials", w.Code)
+//	}
+//
+// It lives in its own package so that programs using the router don't
+// pull in net/http/httptest.
+package routetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// Serve has h serve req and returns the recorded response.
+func Serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// A Request builds an *http.Request for a test.
+type Request struct {
+	method, target string
+	header         http.Header
+	body           io.Reader
+}
+
+// NewRequest starts building a request with the given method and
+// target, which is either a path or an absolute URL.
+func NewRequest(method, target string) *Request {
+	return &Request{method: method, target: target, header: http.Header{}}
+}
+
+// Get starts building a GET request.
+func Get(target string) *Request {
+	return NewRequest("GET", target)
+}
+
+// Post starts building a POST request.
+func Post(target string) *Request {
+	return NewRequest("POST", target)
+}
+
+// Put starts building a PUT request.
+func Put(target string) *Request {
+	return NewRequest("PUT", target)
+}
+
+// Delete starts building a DELETE request.
+func Delete(target string) *Request {
+	return NewRequest("DELETE", target)
+}
+
+// Header adds a header to the request.
+func (r *Request) Header(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+// Body sets the request's body.
+func (r *Request) Body(body string) *Request {
+	r.body = strings.NewReader(body)
+	return r
+}
+
+// JSON sets the request's body to v encoded as JSON, along with its
+// Content-Type. It panics if v can't be encoded, which is a bug in the
+// test.
+func (r *Request) JSON(v interface{}) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic("routetest: " + err.Error())
+	}
+	r.body = bytes.NewReader(b)
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Request returns the built request, ready to be served.
+func (r *Request) Request() *http.Request {
+	req := httptest.NewRequest(r.method, r.target, r.body)
+	for k, vs := range r.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	return req
+}
@@ -0,0 +1,14 @@
+package route
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalize puts p in Unicode normalization form C if the Handler
+// normalizes paths, and returns it unchanged otherwise.
+func (h *Handler) normalize(p string) string {
+	if !h.NormalizeUnicode {
+		return p
+	}
+	return norm.NFC.String(p)
+}
@@ -0,0 +1,48 @@
+package route
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeUnicode(t *testing.T) {
+	const nfc, nfd = "/caf\u00e9", "/cafe\u0301"
+	register := map[string]func(h *Handler){
+		"Get": func(h *Handler) { h.Get(nfd, ok("café")) },
+		"RegisterAll": func(h *Handler) {
+			if err := h.RegisterAll([]Route{{Method: "GET", Pattern: nfd, Handler: ok("café")}}); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"GetMulti": func(h *Handler) { h.GetMulti([]string{nfd, "/coffee"}, ok("café")) },
+		"Reload": func(h *Handler) {
+			if err := h.Reload(func(h2 *Handler) error { h2.Get(nfd, ok("café")); return nil }); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+	for name, reg := range register {
+		h := &Handler{NormalizeUnicode: true}
+		reg(h)
+		for _, p := range []string{nfc, nfd} {
+			if w := do(h, "GET", (&url.URL{Path: p}).EscapedPath()); w.Code != 200 {
+				t.Errorf("%s: %q got %d", name, p, w.Code)
+			}
+		}
+	}
+
+	h := &Handler{NormalizeUnicode: true}
+	var got string
+	h.Get("/tags/:tag", func(w http.ResponseWriter, r *http.Request) { got = r.FormValue(":tag") })
+	do(h, "GET", (&url.URL{Path: "/tags" + nfd}).EscapedPath())
+	if got != nfc[1:] {
+		t.Errorf("captured %q, want %q", got, nfc[1:])
+	}
+
+	h = &Handler{}
+	h.Get(nfd, ok("café"))
+	if w := do(h, "GET", (&url.URL{Path: nfc}).EscapedPath()); w.Code != 404 {
+		t.Errorf("without NormalizeUnicode got %d", w.Code)
+	}
+}